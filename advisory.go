@@ -0,0 +1,193 @@
+package godb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"github.com/dimonrus/gocli"
+	"hash/fnv"
+	"strconv"
+	"sync"
+)
+
+// ErrLockNotAcquired lock was not granted by database
+var ErrLockNotAcquired = errors.New("advisory lock is not acquired")
+
+// AdvisoryKey hash of string key used as postgres advisory lock key
+func AdvisoryKey(key string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	return int64(h.Sum64())
+}
+
+// mysql lock name. Long keys are hashed because of 64 chars limit of lock name
+func mysqlLockName(key string) string {
+	if len(key) <= 64 {
+		return key
+	}
+	return strconv.FormatUint(uint64(AdvisoryKey(key)), 16)
+}
+
+// AdvisoryLock session lock held by dedicated connection
+// Lock is released when handle is closed, context of lock is done or database object is shut down
+type AdvisoryLock struct {
+	key     string
+	dialect string
+	conn    *sql.Conn
+	state   *dboState
+	logger  gocli.Logger
+	once    sync.Once
+	err     error
+	stop    chan struct{}
+}
+
+// AdvisoryLock wait for session lock until context is done
+// Postgres pg_advisory_lock and mysql GET_LOCK are used
+func (dbo *DBO) AdvisoryLock(ctx context.Context, key string) (*AdvisoryLock, error) {
+	l, ok, err := dbo.advisoryLock(ctx, key, true)
+	if err == nil && !ok {
+		err = ErrLockNotAcquired
+	}
+	return l, err
+}
+
+// TryAdvisoryLock acquire session lock without waiting. False is returned if lock is held by other session
+func (dbo *DBO) TryAdvisoryLock(ctx context.Context, key string) (*AdvisoryLock, bool, error) {
+	return dbo.advisoryLock(ctx, key, false)
+}
+
+// acquire lock on dedicated connection
+func (dbo *DBO) advisoryLock(ctx context.Context, key string, wait bool) (*AdvisoryLock, bool, error) {
+	var query string
+	var arg interface{}
+	dialect := dbo.ConnType()
+	switch dialect {
+	case "postgres":
+		arg = AdvisoryKey(key)
+		query = "SELECT pg_try_advisory_lock($1)"
+		if wait {
+			query = "SELECT pg_advisory_lock($1)"
+		}
+	case "mysql":
+		arg = mysqlLockName(key)
+		query = "SELECT COALESCE(GET_LOCK(?, 0), 0) = 1"
+		if wait {
+			query = "SELECT COALESCE(GET_LOCK(?, -1), 0) = 1"
+		}
+	default:
+		return nil, false, ErrUnsupportedDialect
+	}
+	if err := dbo.state.acquire(); err != nil {
+		return nil, false, err
+	}
+	conn, err := dbo.DB.Conn(ctx)
+	if err != nil {
+		dbo.state.release()
+		return nil, false, err
+	}
+	dbo.logQuery(query)
+	var ok bool
+	if dialect == "postgres" && wait {
+		_, err = conn.ExecContext(ctx, query, arg)
+		ok = err == nil
+	} else {
+		err = conn.QueryRowContext(ctx, query, arg).Scan(&ok)
+	}
+	if err != nil || !ok {
+		// lock state is unknown after error, connection is discarded to release lock
+		_ = releaseConn(conn, err != nil)
+		dbo.state.release()
+		return nil, false, err
+	}
+	l := &AdvisoryLock{
+		key:     key,
+		dialect: dialect,
+		conn:    conn,
+		state:   dbo.state,
+		logger:  dbo.Logger,
+		stop:    make(chan struct{}),
+	}
+	// lock is registered before acquisition is finished, so shutdown releases it
+	err = dbo.state.addLock(l)
+	dbo.state.release()
+	if err != nil {
+		_ = l.Close()
+		return nil, false, err
+	}
+	go l.watch(ctx)
+	return l, true, nil
+}
+
+// Key of lock
+func (l *AdvisoryLock) Key() string {
+	return l.key
+}
+
+// Close release lock and return connection to pool. Repeated close returns result of first close
+// Connection is discarded if unlock fails, so database releases lock with session
+func (l *AdvisoryLock) Close() error {
+	l.once.Do(func() {
+		close(l.stop)
+		var err error
+		if l.dialect == "postgres" {
+			_, err = l.conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", AdvisoryKey(l.key))
+		} else {
+			_, err = l.conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", mysqlLockName(l.key))
+		}
+		if e := releaseConn(l.conn, err != nil); err == nil {
+			err = e
+		}
+		l.state.removeLock(l)
+		l.err = err
+	})
+	return l.err
+}
+
+// watch release lock when context is done
+func (l *AdvisoryLock) watch(ctx context.Context) {
+	if ctx.Done() == nil {
+		return
+	}
+	select {
+	case <-ctx.Done():
+		if err := l.Close(); err != nil && l.logger != nil {
+			l.logger.Errorln("advisory lock release: " + err.Error())
+		}
+	case <-l.stop:
+	}
+}
+
+// releaseConn return connection to pool or close physical connection if discard is set
+func releaseConn(conn *sql.Conn, discard bool) error {
+	if discard {
+		_ = conn.Raw(func(interface{}) error {
+			return driver.ErrBadConn
+		})
+	}
+	err := conn.Close()
+	if discard && err == sql.ErrConnDone {
+		err = nil
+	}
+	return err
+}
+
+// AdvisoryXactLock wait for postgres advisory lock released at the end of transaction
+// Mysql has no transaction scoped locks, ErrUnsupportedDialect is returned
+func (tx *SqlTx) AdvisoryXactLock(key string) error {
+	if tx.ConnType() != "postgres" {
+		return ErrUnsupportedDialect
+	}
+	_, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", AdvisoryKey(key))
+	return err
+}
+
+// TryAdvisoryXactLock acquire postgres advisory lock released at the end of transaction without waiting
+func (tx *SqlTx) TryAdvisoryXactLock(key string) (bool, error) {
+	if tx.ConnType() != "postgres" {
+		return false, ErrUnsupportedDialect
+	}
+	var ok bool
+	err := tx.QueryRow("SELECT pg_try_advisory_xact_lock($1)", AdvisoryKey(key)).Scan(&ok)
+	return ok, err
+}
@@ -0,0 +1,190 @@
+package godb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fake postgres with advisory locks. Database is opened with connector, so fake driver is not registered as postgres
+var testLockDriver = &fakeDriver{}
+
+// connection config of fake driver. Postgres if dialect is not set
+type fakeDbConnection struct {
+	dialect string
+}
+
+func (c *fakeDbConnection) String() string {
+	return "locks"
+}
+
+func (c *fakeDbConnection) GetDbType() string {
+	if c.dialect == "" {
+		return "postgres"
+	}
+	return c.dialect
+}
+
+func (c *fakeDbConnection) GetMaxConnection() int {
+	return 10
+}
+
+func (c *fakeDbConnection) GetMaxIdleConns() int {
+	return 5
+}
+
+func (c *fakeDbConnection) GetConnMaxLifetime() int {
+	return 50
+}
+
+func TestAdvisoryLock(t *testing.T) {
+	// fake postgres driver is not registered, database is opened with connector
+	db := &DBO{
+		DB:         sql.OpenDB(testLockDriver),
+		Options:    Options{Logger: newTestLogger()},
+		Connection: &fakeDbConnection{},
+	}
+	defer db.DB.Close()
+	ctx := context.Background()
+	key := AdvisoryKey("leader")
+	t.Run("try", func(t *testing.T) {
+		lock, ok, err := db.TryAdvisoryLock(ctx, "leader")
+		if err != nil || !ok {
+			t.Fatal("lock must be acquired", err)
+		}
+		other, ok, err := db.TryAdvisoryLock(ctx, "leader")
+		if err != nil || ok || other != nil {
+			t.Fatal("lock must be held by first session", err)
+		}
+		if err = lock.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err = lock.Close(); err != nil {
+			t.Fatal("repeated close must not fail", err)
+		}
+		if testLockDriver.holder(key) != nil {
+			t.Fatal("lock must be released")
+		}
+	})
+	t.Run("wait", func(t *testing.T) {
+		lock, err := db.AdvisoryLock(ctx, "leader")
+		if err != nil {
+			t.Fatal(err)
+		}
+		go func() {
+			time.Sleep(time.Millisecond * 50)
+			lock.Close()
+		}()
+		next, err := db.AdvisoryLock(ctx, "leader")
+		if err != nil {
+			t.Fatal("lock must be acquired after release", err)
+		}
+		next.Close()
+		timeoutCtx, cancel := context.WithTimeout(ctx, time.Millisecond*20)
+		defer cancel()
+		lock, err = db.AdvisoryLock(ctx, "leader")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer lock.Close()
+		if _, err = db.AdvisoryLock(timeoutCtx, "leader"); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatal("lock must wait until context is done", err)
+		}
+	})
+	t.Run("context", func(t *testing.T) {
+		lockCtx, cancel := context.WithCancel(ctx)
+		lock, err := db.AdvisoryLock(lockCtx, "context")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lock.Key() != "context" || testLockDriver.holder(AdvisoryKey("context")) == nil {
+			t.Fatal("lock must be held")
+		}
+		cancel()
+		deadline := time.Now().Add(time.Second)
+		for testLockDriver.holder(AdvisoryKey("context")) != nil {
+			if time.Now().After(deadline) {
+				t.Fatal("lock must be released on context cancel")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	})
+	t.Run("shutdown", func(t *testing.T) {
+		db := &DBO{
+			DB:         sql.OpenDB(testLockDriver),
+			Connection: &fakeDbConnection{},
+			state:      newDboState(),
+		}
+		lock, err := db.AdvisoryLock(ctx, "shutdown")
+		if err != nil {
+			t.Fatal(err)
+		}
+		shutdownCtx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		if err = db.Shutdown(shutdownCtx); err != nil {
+			t.Fatal("shutdown must not wait for held lock", err)
+		}
+		if testLockDriver.holder(AdvisoryKey("shutdown")) != nil {
+			t.Fatal("lock must be released by shutdown")
+		}
+		if err = lock.Close(); err != nil {
+			t.Fatal("close after shutdown must return result of release", err)
+		}
+		if _, err = db.AdvisoryLock(ctx, "other"); err != ErrDBOClosed {
+			t.Fatal("lock must not be acquired after shutdown", err)
+		}
+	})
+	t.Run("shutdown_deadline", func(t *testing.T) {
+		db := &DBO{
+			DB:         sql.OpenDB(testLockDriver),
+			Connection: &fakeDbConnection{},
+			state:      newDboState(),
+		}
+		lock, err := db.AdvisoryLock(ctx, "deadline")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer lock.Close()
+		conn := &heldConn{state: db.state}
+		if err = db.state.addConn(conn); err != nil {
+			t.Fatal(err)
+		}
+		shutdownCtx, cancel := context.WithTimeout(ctx, time.Millisecond*50)
+		defer cancel()
+		if err = db.Shutdown(shutdownCtx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatal("shutdown must report deadline", err)
+		}
+		if !conn.closed || testLockDriver.holder(AdvisoryKey("deadline")) != nil {
+			t.Fatal("connection must be closed and lock released by deadline")
+		}
+	})
+	t.Run("unsupported", func(t *testing.T) {
+		sqlite := initSqlite(t, Options{})
+		if _, err := sqlite.AdvisoryLock(ctx, "leader"); err != ErrUnsupportedDialect {
+			t.Fatal("sqlite has no advisory locks", err)
+		}
+		tx, err := sqlite.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tx.Rollback()
+		if err = tx.AdvisoryXactLock("leader"); err != ErrUnsupportedDialect {
+			t.Fatal("sqlite has no advisory locks", err)
+		}
+	})
+}
+
+func TestAdvisoryKey(t *testing.T) {
+	if AdvisoryKey("leader") != AdvisoryKey("leader") || AdvisoryKey("leader") == AdvisoryKey("follower") {
+		t.Fatal("key must be stable hash")
+	}
+	if mysqlLockName("leader") != "leader" {
+		t.Fatal("short mysql name must be used as is")
+	}
+	long := mysqlLockName(string(make([]byte, 100)))
+	if len(long) > 64 {
+		t.Fatal("long mysql name must be hashed", long)
+	}
+}
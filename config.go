@@ -1,6 +1,33 @@
 package godb
 
-import "fmt"
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultEnvPrefix standard postgres environment variables prefix
+	DefaultEnvPrefix = "PG"
+	// DefaultPostgresPort default postgres port
+	DefaultPostgresPort = 5432
+	// DefaultMaxConnections default maximum connections
+	DefaultMaxConnections = 10
+	// DefaultMaxIdleConnections default maximum idle connections
+	DefaultMaxIdleConnections = 2
+)
+
+// Supported ssl modes
+var sslModes = map[string]struct{}{
+	"disable":     {},
+	"allow":       {},
+	"prefer":      {},
+	"require":     {},
+	"verify-ca":   {},
+	"verify-full": {},
+}
 
 // ConnectionConfig Client connection config
 type ConnectionConfig struct {
@@ -14,12 +41,18 @@ type ConnectionConfig struct {
 	User string `yaml:"user"`
 	// Database password
 	Password string `yaml:"password"`
+	// Path to file contains database password
+	PasswordFile string `yaml:"passwordFile"`
 	// Maximum connections
 	MaxConnections int `yaml:"maxConnections"`
 	// Maximum idle connections count
 	MaxIdleConnections int `yaml:"maxIdleConnections"`
-	// Connection idle lifetime
+	// Connection lifetime in seconds. Deprecated: use ConnectionMaxLifetime
 	ConnectionIdleLifetime int `yaml:"connectionIdleLifetime"`
+	// Maximum amount of time a connection may be reused
+	ConnectionMaxLifetime time.Duration `yaml:"connectionMaxLifetime"`
+	// Maximum amount of time a connection may be idle
+	ConnectionMaxIdleTime time.Duration `yaml:"connectionMaxIdleTime"`
 }
 
 // * disable - No SSL
@@ -37,6 +70,8 @@ type PostgresConnectionConfig struct {
 	SSLMode string `yaml:"sslMode"`
 	// Use with pgpool
 	BinaryParameters bool `yaml:"binaryParameters"`
+	// Path to libpq password file of hostname:port:database:username:password lines. Used if password is empty
+	PassFile string `yaml:"passFile"`
 }
 
 // To string
@@ -51,6 +86,14 @@ func (pcc *PostgresConnectionConfig) String() string {
 	return fmt.Sprintf(stringConnection, pcc.Host, pcc.Port, pcc.User, pcc.Password, pcc.Name)
 }
 
+// DSN connection string with provided credentials
+func (pcc *PostgresConnectionConfig) DSN(credentials Credentials) string {
+	config := *pcc
+	config.User = credentials.User
+	config.Password = credentials.Password
+	return config.String()
+}
+
 // GetDbType Get database type
 func (pcc *PostgresConnectionConfig) GetDbType() string {
 	return "postgres"
@@ -66,7 +109,257 @@ func (cc *ConnectionConfig) GetMaxIdleConns() int {
 	return cc.MaxIdleConnections
 }
 
-// GetConnMaxLifetime Connection idle lifetime
+// GetConnMaxLifetime Connection lifetime in seconds
 func (cc *ConnectionConfig) GetConnMaxLifetime() int {
+	if cc.ConnectionIdleLifetime == 0 && cc.ConnectionMaxLifetime > 0 {
+		return int(cc.ConnectionMaxLifetime / time.Second)
+	}
 	return cc.ConnectionIdleLifetime
 }
+
+// GetConnectionMaxLifetime Connection max lifetime
+func (cc *ConnectionConfig) GetConnectionMaxLifetime() time.Duration {
+	if cc.ConnectionMaxLifetime == 0 {
+		return time.Second * time.Duration(cc.ConnectionIdleLifetime)
+	}
+	return cc.ConnectionMaxLifetime
+}
+
+// GetConnectionMaxIdleTime Connection max idle time
+func (cc *ConnectionConfig) GetConnectionMaxIdleTime() time.Duration {
+	return cc.ConnectionMaxIdleTime
+}
+
+// ApplyEnv overlay config by environment variables and read password file
+// Variables are <prefix>HOST, <prefix>PORT, <prefix>DATABASE, <prefix>USER, <prefix>PASSWORD
+func (cc *ConnectionConfig) ApplyEnv(prefix string) error {
+	e := &ConfigError{}
+	if v, ok := os.LookupEnv(prefix + "HOST"); ok {
+		cc.Host = v
+	}
+	if v, ok := os.LookupEnv(prefix + "PORT"); ok {
+		port, err := strconv.Atoi(v)
+		if err != nil {
+			e.Add(prefix+"PORT", "must be a number")
+		} else {
+			cc.Port = port
+		}
+	}
+	if v, ok := os.LookupEnv(prefix + "DATABASE"); ok {
+		cc.Name = v
+	}
+	if v, ok := os.LookupEnv(prefix + "USER"); ok {
+		cc.User = v
+	}
+	if v, ok := os.LookupEnv(prefix + "PASSWORD"); ok {
+		cc.Password = v
+	}
+	if cc.PasswordFile != "" {
+		password, err := os.ReadFile(cc.PasswordFile)
+		if err != nil {
+			e.Add("passwordFile", err.Error())
+		} else {
+			cc.Password = strings.TrimRight(string(password), "\r\n")
+		}
+	}
+	return e.Err()
+}
+
+// Validate check config fields
+func (cc *ConnectionConfig) Validate() error {
+	e := &ConfigError{}
+	cc.validate(e)
+	return e.Err()
+}
+
+// ValidatePool check pool settings only. Used by Init because driver may take connection fields from environment
+func (cc *ConnectionConfig) ValidatePool() error {
+	e := &ConfigError{}
+	cc.validatePool(e)
+	return e.Err()
+}
+
+// validate collect config errors
+func (cc *ConnectionConfig) validate(e *ConfigError) {
+	if cc.Host == "" {
+		e.Add("host", "is required")
+	}
+	if cc.Port < 0 || cc.Port > 65535 {
+		e.Add("port", "must be between 0 and 65535")
+	}
+	if cc.Name == "" {
+		e.Add("name", "is required")
+	}
+	if cc.User == "" {
+		e.Add("user", "is required")
+	}
+	cc.validatePool(e)
+}
+
+// validatePool collect pool settings errors
+func (cc *ConnectionConfig) validatePool(e *ConfigError) {
+	if cc.MaxConnections < 0 {
+		e.Add("maxConnections", "must not be negative")
+	}
+	if cc.MaxIdleConnections < 0 {
+		e.Add("maxIdleConnections", "must not be negative")
+	} else if cc.MaxConnections > 0 && cc.MaxIdleConnections > cc.MaxConnections {
+		e.Add("maxIdleConnections", "must not be greater than maxConnections")
+	}
+	if cc.ConnectionIdleLifetime < 0 {
+		e.Add("connectionIdleLifetime", "must not be negative")
+	}
+	if cc.ConnectionMaxLifetime < 0 {
+		e.Add("connectionMaxLifetime", "must not be negative")
+	}
+	if cc.ConnectionMaxIdleTime < 0 {
+		e.Add("connectionMaxIdleTime", "must not be negative")
+	}
+}
+
+// SetDefaults fill empty pool settings by defaults
+func (cc *ConnectionConfig) SetDefaults() {
+	if cc.MaxConnections == 0 {
+		cc.MaxConnections = DefaultMaxConnections
+	}
+	if cc.MaxIdleConnections == 0 {
+		cc.MaxIdleConnections = DefaultMaxIdleConnections
+		if cc.MaxIdleConnections > cc.MaxConnections {
+			cc.MaxIdleConnections = cc.MaxConnections
+		}
+	}
+	if cc.ConnectionMaxLifetime == 0 && cc.ConnectionIdleLifetime > 0 {
+		cc.ConnectionMaxLifetime = time.Second * time.Duration(cc.ConnectionIdleLifetime)
+	}
+}
+
+// ApplyEnv overlay config by environment variables and read password file
+// In addition to connection variables <prefix>SSLMODE and <prefix>PASSFILE are applied
+// Password file has libpq .pgpass format, password of the first matching line is used if password is not set
+func (pcc *PostgresConnectionConfig) ApplyEnv(prefix string) error {
+	e := &ConfigError{}
+	e.Merge(pcc.ConnectionConfig.ApplyEnv(prefix))
+	if v, ok := os.LookupEnv(prefix + "SSLMODE"); ok {
+		pcc.SSLMode = v
+	}
+	if v, ok := os.LookupEnv(prefix + "PASSFILE"); ok {
+		pcc.PassFile = v
+	}
+	if pcc.PassFile != "" && pcc.Password == "" {
+		data, err := os.ReadFile(pcc.PassFile)
+		if err != nil {
+			e.Add("passFile", err.Error())
+		} else {
+			pcc.Password = pcc.passFilePassword(string(data))
+		}
+	}
+	return e.Err()
+}
+
+// passFilePassword password of the first line matching connection. Empty host matches localhost, empty port matches default
+func (pcc *PostgresConnectionConfig) passFilePassword(data string) string {
+	host, port := pcc.Host, pcc.Port
+	if host == "" || strings.HasPrefix(host, "/") {
+		host = "localhost"
+	}
+	if port == 0 {
+		port = DefaultPostgresPort
+	}
+	values := []string{host, strconv.Itoa(port), pcc.Name, pcc.User}
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := splitPassFileLine(line)
+		if len(fields) != 5 {
+			continue
+		}
+		matched := true
+		for i, value := range values {
+			if fields[i] != "*" && fields[i] != value {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return fields[4]
+		}
+	}
+	return ""
+}
+
+// splitPassFileLine split password file line by colons. Backslash escapes colon and backslash
+func splitPassFileLine(line string) []string {
+	var fields []string
+	var field strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '\\' && i+1 < len(line):
+			i++
+			field.WriteByte(line[i])
+		case c == ':' && len(fields) < 4:
+			fields = append(fields, field.String())
+			field.Reset()
+		default:
+			field.WriteByte(c)
+		}
+	}
+	return append(fields, field.String())
+}
+
+// Validate check config fields
+func (pcc *PostgresConnectionConfig) Validate() error {
+	e := &ConfigError{}
+	pcc.validate(e)
+	return e.Err()
+}
+
+// validate collect config errors
+func (pcc *PostgresConnectionConfig) validate(e *ConfigError) {
+	pcc.ConnectionConfig.validate(e)
+	if _, ok := sslModes[pcc.SSLMode]; pcc.SSLMode != "" && !ok {
+		e.Add("sslMode", "unknown mode "+pcc.SSLMode)
+	}
+}
+
+// SetDefaults fill empty port and pool settings by defaults
+func (pcc *PostgresConnectionConfig) SetDefaults() {
+	pcc.ConnectionConfig.SetDefaults()
+	if pcc.Port == 0 {
+		pcc.Port = DefaultPostgresPort
+	}
+}
+
+// DatabaseConfig postgres connection config with transaction options
+type DatabaseConfig struct {
+	PostgresConnectionConfig `yaml:",inline"`
+	// TTL for transaction. Use it for Options.TransactionTTL
+	TransactionTTL time.Duration `yaml:"transactionTTL"`
+}
+
+// ApplyEnv overlay config by environment variables and read password file
+// In addition to postgres variables <prefix>TRANSACTION_TTL is applied
+func (dc *DatabaseConfig) ApplyEnv(prefix string) error {
+	e := &ConfigError{}
+	e.Merge(dc.PostgresConnectionConfig.ApplyEnv(prefix))
+	if v, ok := os.LookupEnv(prefix + "TRANSACTION_TTL"); ok {
+		ttl, err := time.ParseDuration(v)
+		if err != nil {
+			e.Add(prefix+"TRANSACTION_TTL", "must be a duration")
+		} else {
+			dc.TransactionTTL = ttl
+		}
+	}
+	return e.Err()
+}
+
+// Validate check config fields
+func (dc *DatabaseConfig) Validate() error {
+	e := &ConfigError{}
+	dc.PostgresConnectionConfig.validate(e)
+	if dc.TransactionTTL < 0 {
+		e.Add("transactionTTL", "must not be negative")
+	}
+	return e.Err()
+}
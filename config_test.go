@@ -0,0 +1,180 @@
+package godb
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "db.yaml")
+	secret := filepath.Join(dir, "password")
+	err := os.WriteFile(file, []byte(`
+host: localhost
+port: 5432
+name: migrate
+user: migrate
+password: from_file
+sslMode: disable
+maxConnections: 10
+transactionTTL: 30s
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(secret, []byte("secret\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("yaml", func(t *testing.T) {
+		config := DatabaseConfig{}
+		err = LoadConfig(file, "TEST_NONE_", &config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.Host != "localhost" || config.Port != 5432 || config.Password != "from_file" {
+			t.Fatal("wrong yaml config", config)
+		}
+		if config.TransactionTTL != time.Second*30 {
+			t.Fatal("wrong ttl", config.TransactionTTL)
+		}
+	})
+
+	t.Run("env", func(t *testing.T) {
+		t.Setenv("PGHOST", "db.svc")
+		t.Setenv("PGPORT", "6432")
+		t.Setenv("PGSSLMODE", "verify-full")
+		t.Setenv("PGTRANSACTION_TTL", "1m")
+		config := DatabaseConfig{}
+		err = LoadConfig(file, "", &config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.Host != "db.svc" || config.Port != 6432 || config.SSLMode != "verify-full" {
+			t.Fatal("wrong env config", config)
+		}
+		if config.TransactionTTL != time.Minute {
+			t.Fatal("wrong ttl", config.TransactionTTL)
+		}
+	})
+
+	t.Run("passfile", func(t *testing.T) {
+		passFile := filepath.Join(dir, "pgpass")
+		err = os.WriteFile(passFile, []byte(`# comment
+db.svc:5432:*:migrate:wrong_port
+db.svc:*:migrate:migrate:pa\:ss\\
+*:*:*:*:fallback
+`), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("PGHOST", "db.svc")
+		t.Setenv("PGPORT", "6432")
+		t.Setenv("PGDATABASE", "migrate")
+		t.Setenv("PGUSER", "migrate")
+		t.Setenv("PGPASSFILE", passFile)
+		config := PostgresConnectionConfig{}
+		err = LoadConfig("", "", &config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.Password != `pa:ss\` {
+			t.Fatal("password must be read from the first matching line", config.Password)
+		}
+		t.Setenv("PGPASSWORD", "explicit")
+		config = PostgresConnectionConfig{}
+		if err = LoadConfig("", "", &config); err != nil || config.Password != "explicit" {
+			t.Fatal("password must take precedence over password file", config.Password, err)
+		}
+	})
+
+	t.Run("password file", func(t *testing.T) {
+		config := DatabaseConfig{}
+		config.PasswordFile = secret
+		err = LoadConfig(file, "TEST_NONE_", &config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.Password != "secret" {
+			t.Fatal("password must be read from file")
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		t.Setenv("APP_DB_PORT", "abc")
+		t.Setenv("APP_DB_PASSFILE", filepath.Join(dir, "missing"))
+		config := PostgresConnectionConfig{SSLMode: "unknown"}
+		err = LoadConfig("", "APP_DB_", &config)
+		e, ok := err.(*ConfigError)
+		if !ok {
+			t.Fatal("must be config error", err)
+		}
+		// port, password file, ssl mode, host, name, user
+		if len(e.Fields) != 6 {
+			t.Fatal("wrong fields count", e.Error())
+		}
+		t.Log(e.Error())
+	})
+}
+
+func TestConnectionConfigValidate(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		config := PostgresConnectionConfig{ConnectionConfig: ConnectionConfig{Host: "localhost", Name: "db", User: "user", ConnectionIdleLifetime: 60}}
+		config.SetDefaults()
+		if config.Port != DefaultPostgresPort || config.MaxConnections != DefaultMaxConnections || config.MaxIdleConnections != DefaultMaxIdleConnections {
+			t.Fatal("wrong defaults", config)
+		}
+		if config.GetConnectionMaxLifetime() != time.Minute {
+			t.Fatal("wrong max lifetime", config.GetConnectionMaxLifetime())
+		}
+		if err := config.Validate(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("impossible", func(t *testing.T) {
+		config := ConnectionConfig{
+			Name:                  "db",
+			User:                  "user",
+			MaxConnections:        2,
+			MaxIdleConnections:    5,
+			ConnectionMaxIdleTime: -time.Second,
+		}
+		err := config.Validate()
+		e, ok := err.(*ConfigError)
+		if !ok {
+			t.Fatal("must be config error", err)
+		}
+		// host, idle > max, negative idle time
+		if len(e.Fields) != 3 {
+			t.Fatal("wrong fields count", e.Error())
+		}
+	})
+	t.Run("init", func(t *testing.T) {
+		_, err := DBO{Connection: &PostgresConnectionConfig{ConnectionConfig: ConnectionConfig{MaxIdleConnections: -1}}}.Init()
+		e, ok := err.(*ConfigError)
+		if !ok {
+			t.Fatal("init must validate pool settings", err)
+		}
+		if len(e.Fields) != 1 || e.Fields[0].Field != "maxIdleConnections" {
+			t.Fatal("init must validate pool settings only", e.Error())
+		}
+	})
+	t.Run("init defaults", func(t *testing.T) {
+		testFakeDriver.reset(0)
+		connection := &fakeConnection{}
+		db, err := DBO{Connection: connection}.Init()
+		if err != nil {
+			t.Fatal("host, name and user must not be required", err)
+		}
+		defer db.DB.Close()
+		if connection.MaxConnections != DefaultMaxConnections || connection.MaxIdleConnections != DefaultMaxIdleConnections {
+			t.Fatal("defaults must be set before validation", connection.ConnectionConfig)
+		}
+		if db.Stats().MaxOpenConnections != DefaultMaxConnections {
+			t.Fatal("default max connections must be applied", db.Stats().MaxOpenConnections)
+		}
+	})
+}
@@ -0,0 +1,99 @@
+package godb
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// SqlConn dedicated connection for session-level features: SET, temporary tables, advisory locks
+// Connection must be released with Close
+type SqlConn struct {
+	*sql.Conn
+	Options
+	Connection Connection
+	// database object
+	dbo *DBO
+	// connection is released
+	once sync.Once
+	err  error
+}
+
+// Conn reserve dedicated connection. Shutdown waits until connection is released and closes it by deadline
+func (dbo *DBO) Conn(ctx context.Context) (*SqlConn, error) {
+	if err := dbo.state.acquire(); err != nil {
+		return nil, err
+	}
+	defer dbo.state.release()
+	conn, err := dbo.DB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c := &SqlConn{Conn: conn, Options: dbo.Options, Connection: dbo.Connection, dbo: dbo}
+	if err = dbo.state.addConn(c); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Close return connection to pool. Repeated close returns result of first close
+func (c *SqlConn) Close() error {
+	c.once.Do(func() {
+		c.err = c.Conn.Close()
+		c.dbo.state.removeConn(c)
+	})
+	return c.err
+}
+
+// ConnType get connection type
+func (c *SqlConn) ConnType() string {
+	return c.Connection.GetDbType()
+}
+
+// Query SQL exec query
+func (c *SqlConn) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	if c.Options.QueryProcessor != nil {
+		query = c.Options.QueryProcessor(query)
+	}
+	c.logQuery(query)
+	return c.Conn.QueryContext(context.Background(), query, args...)
+}
+
+// Exec SQL run query
+func (c *SqlConn) Exec(query string, args ...interface{}) (sql.Result, error) {
+	if c.Options.QueryProcessor != nil {
+		query = c.Options.QueryProcessor(query)
+	}
+	c.logQuery(query)
+	return c.Conn.ExecContext(context.Background(), query, args...)
+}
+
+// QueryRow SQL query row
+func (c *SqlConn) QueryRow(query string, args ...interface{}) *sql.Row {
+	if c.Options.QueryProcessor != nil {
+		query = c.Options.QueryProcessor(query)
+	}
+	c.logQuery(query)
+	return c.Conn.QueryRowContext(context.Background(), query, args...)
+}
+
+// Prepare statement on connection
+func (c *SqlConn) Prepare(query string) (*SqlStmt, error) {
+	args := countArgs(query)
+	if c.Options.QueryProcessor != nil {
+		query = c.Options.QueryProcessor(query)
+	}
+	stmt, err := c.Conn.PrepareContext(context.Background(), query)
+	return newStmt(stmt, c.Options, query, args, nil), err
+}
+
+// Begin transaction on connection
+func (c *SqlConn) Begin() (*SqlTx, error) {
+	return c.BeginContext(context.Background(), nil)
+}
+
+// BeginContext begin transaction with options on connection. Transaction is rolled back when context is done or TTL expires
+func (c *SqlConn) BeginContext(ctx context.Context, opts *TxOptions) (*SqlTx, error) {
+	return c.dbo.begin(ctx, opts, c.Conn.BeginTx)
+}
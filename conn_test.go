@@ -0,0 +1,127 @@
+package godb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+var _ Queryer = (*SqlConn)(nil)
+
+func TestSqlConn(t *testing.T) {
+	lg := newTestLogger()
+	db := initSqlite(t, Options{
+		Debug:   true,
+		Logger:  lg,
+		LogSync: true,
+		QueryProcessor: func(query string) string {
+			return query + " /* conn */"
+		},
+	})
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Run("session", func(t *testing.T) {
+		if conn.ConnType() != "sqlite3" {
+			t.Fatal("wrong connection type")
+		}
+		if _, err = conn.Exec("CREATE TEMP TABLE session_test (id INTEGER)"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = conn.Exec("INSERT INTO session_test (id) VALUES (?)", 1); err != nil {
+			t.Fatal(err)
+		}
+		var count int
+		if err = conn.QueryRow("SELECT count(*) FROM session_test").Scan(&count); err != nil || count != 1 {
+			t.Fatal("temporary table must be visible on connection", count, err)
+		}
+		lg.m.Lock()
+		last := lg.messages[len(lg.messages)-1]
+		lg.m.Unlock()
+		if !strings.HasSuffix(last, "/* conn */") {
+			t.Fatal("query must be processed and logged", last)
+		}
+		if ok, err := TableExists(conn, "session_test", "temp"); err != nil || !ok {
+			t.Fatal("temporary table must exist on connection", err)
+		}
+		other, err := db.Conn(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer other.Close()
+		if ok, err := TableExists(other, "session_test", "temp"); err != nil || ok {
+			t.Fatal("temporary table must not be visible on other connection", err)
+		}
+	})
+	t.Run("statement", func(t *testing.T) {
+		stmt, err := conn.Prepare("SELECT id FROM session_test WHERE id = ?")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stmt.Close()
+		var id int
+		if err = stmt.QueryRow(1).Scan(&id); err != nil || id != 1 {
+			t.Fatal("wrong statement result", id, err)
+		}
+	})
+	t.Run("transaction", func(t *testing.T) {
+		tx, err := conn.BeginContext(ctx, &TxOptions{TTL: time.Second})
+		if err != nil {
+			t.Fatal(err)
+		}
+		committed := false
+		tx.OnCommit(func() {
+			committed = true
+		})
+		if _, err = tx.Exec("INSERT INTO session_test (id) VALUES (?)", 2); err != nil {
+			t.Fatal(err)
+		}
+		if err = tx.Commit(); err != nil || !committed {
+			t.Fatal("transaction must be committed with hooks", err)
+		}
+		var count int
+		if err = conn.QueryRow("SELECT count(*) FROM session_test").Scan(&count); err != nil || count != 2 {
+			t.Fatal("transaction must use connection", count, err)
+		}
+	})
+	t.Run("shutdown", func(t *testing.T) {
+		go func() {
+			time.Sleep(time.Millisecond * 50)
+			conn.Close()
+		}()
+		shutdownCtx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		if err = db.Shutdown(shutdownCtx); err != nil {
+			t.Fatal("shutdown must wait for connection release", err)
+		}
+		if err = conn.Close(); err != nil {
+			t.Fatal("repeated close must return first result", err)
+		}
+		if _, err = db.Conn(ctx); err != ErrDBOClosed {
+			t.Fatal("connection must not be reserved after shutdown", err)
+		}
+	})
+	t.Run("shutdown_deadline", func(t *testing.T) {
+		db := initSqlite(t, Options{})
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = db.Exec("SELECT 1"); err != nil {
+			t.Fatal("held connection must not block queries", err)
+		}
+		shutdownCtx, cancel := context.WithTimeout(ctx, time.Millisecond*50)
+		defer cancel()
+		if err = db.Shutdown(shutdownCtx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatal("shutdown must report deadline", err)
+		}
+		if _, err = conn.Exec("SELECT 1"); !errors.Is(err, sql.ErrConnDone) {
+			t.Fatal("connection must be closed by deadline", err)
+		}
+	})
+}
@@ -0,0 +1,225 @@
+package godb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"github.com/dimonrus/gocli"
+	"sync"
+	"time"
+)
+
+// ErrNoCredentialsDSN connection can not build dsn with credentials
+var ErrNoCredentialsDSN = errors.New("connection does not implement CredentialsDSN")
+
+// Credentials database user credentials
+type Credentials struct {
+	// Database user
+	User string
+	// Database password
+	Password string
+}
+
+// CredentialProvider return actual credentials
+type CredentialProvider func(ctx context.Context) (Credentials, error)
+
+// CredentialsDSN connection which can build dsn with provided credentials
+type CredentialsDSN interface {
+	// DSN return connection string with credentials
+	DSN(credentials Credentials) string
+}
+
+// ConnectorConnection connection which opens physical connections with driver connector
+type ConnectorConnection interface {
+	Connection
+	// Connector create driver connector
+	Connector(d driver.Driver) (driver.Connector, error)
+}
+
+// RotatingConnection connection with credentials obtained from provider for every new physical connection
+type RotatingConnection struct {
+	// Connection config. Must implement CredentialsDSN
+	Connection
+	// Credentials provider
+	Provider CredentialProvider
+	// Interval of credentials refresh in background. 0 - credentials are checked on new connections and Refresh only
+	RefreshInterval time.Duration
+	// last obtained credentials
+	current *Credentials
+	// callbacks on credentials rotation of each database object
+	onRotate map[int]func()
+	nextId   int
+	m        sync.Mutex
+}
+
+// Connector create driver connector
+func (rc *RotatingConnection) Connector(d driver.Driver) (driver.Connector, error) {
+	dsn, ok := rc.Connection.(CredentialsDSN)
+	if !ok {
+		return nil, ErrNoCredentialsDSN
+	}
+	return &connector{
+		driver: d,
+		dsn: func(ctx context.Context) (string, error) {
+			credentials, err := rc.Provider(ctx)
+			if err != nil {
+				return "", err
+			}
+			rc.update(credentials)
+			return dsn.DSN(credentials), nil
+		},
+	}, nil
+}
+
+// GetConnectionMaxLifetime return maximum amount of time a connection may be reused
+func (rc *RotatingConnection) GetConnectionMaxLifetime() time.Duration {
+	if t, ok := rc.Connection.(ConnectionTimeouts); ok {
+		return t.GetConnectionMaxLifetime()
+	}
+	return time.Second * time.Duration(rc.GetConnMaxLifetime())
+}
+
+// GetConnectionMaxIdleTime return maximum amount of time a connection may be idle
+func (rc *RotatingConnection) GetConnectionMaxIdleTime() time.Duration {
+	if t, ok := rc.Connection.(ConnectionTimeouts); ok {
+		return t.GetConnectionMaxIdleTime()
+	}
+	return 0
+}
+
+// Validate check credentials provider is set
+// Connection config is not validated because credentials are obtained from provider
+func (rc *RotatingConnection) Validate() error {
+	e := &ConfigError{}
+	rc.validate(e)
+	return e.Err()
+}
+
+// ValidatePool check credentials provider and pool settings of connection config
+func (rc *RotatingConnection) ValidatePool() error {
+	e := &ConfigError{}
+	rc.validate(e)
+	if v, ok := rc.Connection.(PoolConfig); ok {
+		e.Merge(v.ValidatePool())
+	}
+	return e.Err()
+}
+
+// SetDefaults fill empty settings of connection config by defaults
+func (rc *RotatingConnection) SetDefaults() {
+	if d, ok := rc.Connection.(ConfigDefaults); ok {
+		d.SetDefaults()
+	}
+}
+
+// validate collect provider errors
+func (rc *RotatingConnection) validate(e *ConfigError) {
+	if rc.Provider == nil {
+		e.Add("provider", "is required")
+	}
+	if _, ok := rc.Connection.(CredentialsDSN); !ok {
+		e.Add("connection", "must implement CredentialsDSN")
+	}
+}
+
+// Refresh obtain credentials from provider and recycle idle connections if they were rotated
+func (rc *RotatingConnection) Refresh(ctx context.Context) error {
+	credentials, err := rc.Provider(ctx)
+	if err != nil {
+		return err
+	}
+	rc.update(credentials)
+	return nil
+}
+
+// update current credentials and call rotation callbacks if they were changed
+func (rc *RotatingConnection) update(credentials Credentials) {
+	rc.m.Lock()
+	rotated := rc.current != nil && *rc.current != credentials
+	rc.current = &credentials
+	var callbacks []func()
+	if rotated {
+		for _, fn := range rc.onRotate {
+			callbacks = append(callbacks, fn)
+		}
+	}
+	rc.m.Unlock()
+	for _, fn := range callbacks {
+		fn()
+	}
+}
+
+// addOnRotate add rotation callback. Returned function removes callback
+func (rc *RotatingConnection) addOnRotate(fn func()) func() {
+	rc.m.Lock()
+	defer rc.m.Unlock()
+	if rc.onRotate == nil {
+		rc.onRotate = make(map[int]func())
+	}
+	id := rc.nextId
+	rc.nextId++
+	rc.onRotate[id] = fn
+	return func() {
+		rc.m.Lock()
+		delete(rc.onRotate, id)
+		rc.m.Unlock()
+	}
+}
+
+// refresh credentials every refresh interval until database object is shut down. Rotation callback is removed on shutdown
+func (rc *RotatingConnection) refresh(state *dboState, logger gocli.Logger, remove func()) {
+	defer remove()
+	ticker := time.NewTicker(rc.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-state.done:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), rc.RefreshInterval)
+			err := rc.Refresh(ctx)
+			cancel()
+			if err != nil && logger != nil {
+				logger.Errorln("credentials refresh: " + err.Error())
+			}
+		}
+	}
+}
+
+// connector open physical connections with dsn built on each connect
+type connector struct {
+	driver driver.Driver
+	dsn    func(ctx context.Context) (string, error)
+}
+
+// Connect open physical connection
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	dsn, err := c.dsn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if dc, ok := c.driver.(driver.DriverContext); ok {
+		cn, err := dc.OpenConnector(dsn)
+		if err != nil {
+			return nil, err
+		}
+		return cn.Connect(ctx)
+	}
+	return c.driver.Open(dsn)
+}
+
+// Driver return underlying driver
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
+
+// Get driver registered by name
+func lookupDriver(name string) (driver.Driver, error) {
+	db, err := sql.Open(name, "")
+	if err != nil {
+		return nil, err
+	}
+	d := db.Driver()
+	return d, db.Close()
+}
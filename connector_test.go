@@ -0,0 +1,314 @@
+package godb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fake driver records opened dsn and executed queries, emulates postgres advisory locks
+// Driver is also a connector, so database can be opened with sql.OpenDB
+type fakeDriver struct {
+	m sync.Mutex
+	// opened dsn
+	dsn []string
+	// fail next n connections
+	fail int
+	// executed queries
+	queries []string
+	// advisory lock holders by key. Locks are released when session is closed
+	held map[int64]*fakeConn
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	d.m.Lock()
+	defer d.m.Unlock()
+	if d.fail > 0 {
+		d.fail--
+		return nil, errors.New("fake connection refused")
+	}
+	d.dsn = append(d.dsn, name)
+	return &fakeConn{d: d}, nil
+}
+
+func (d *fakeDriver) Connect(ctx context.Context) (driver.Conn, error) {
+	return d.Open("")
+}
+
+func (d *fakeDriver) Driver() driver.Driver {
+	return d
+}
+
+func (d *fakeDriver) opened() []string {
+	d.m.Lock()
+	defer d.m.Unlock()
+	return append([]string(nil), d.dsn...)
+}
+
+func (d *fakeDriver) reset(fail int) {
+	d.m.Lock()
+	d.dsn = nil
+	d.fail = fail
+	d.m.Unlock()
+}
+
+// record executed query
+func (d *fakeDriver) record(query string) {
+	d.m.Lock()
+	d.queries = append(d.queries, query)
+	d.m.Unlock()
+}
+
+// last n executed queries
+func (d *fakeDriver) last(n int) []string {
+	d.m.Lock()
+	defer d.m.Unlock()
+	if len(d.queries) < n {
+		return append([]string(nil), d.queries...)
+	}
+	return append([]string(nil), d.queries[len(d.queries)-n:]...)
+}
+
+// try lock key by session
+func (d *fakeDriver) try(c *fakeConn, key int64) bool {
+	d.m.Lock()
+	defer d.m.Unlock()
+	if holder, ok := d.held[key]; ok && holder != c {
+		return false
+	}
+	if d.held == nil {
+		d.held = make(map[int64]*fakeConn)
+	}
+	d.held[key] = c
+	return true
+}
+
+// unlock key held by session
+func (d *fakeDriver) unlock(c *fakeConn, key int64) {
+	d.m.Lock()
+	defer d.m.Unlock()
+	if d.held[key] == c {
+		delete(d.held, key)
+	}
+}
+
+// holder of key
+func (d *fakeDriver) holder(key int64) *fakeConn {
+	d.m.Lock()
+	defer d.m.Unlock()
+	return d.held[key]
+}
+
+type fakeConn struct {
+	d *fakeDriver
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (c *fakeConn) Close() error {
+	c.d.m.Lock()
+	defer c.d.m.Unlock()
+	for key, holder := range c.d.held {
+		if holder == c {
+			delete(c.d.held, key)
+		}
+	}
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.d.record("BEGIN")
+	return c, nil
+}
+
+func (c *fakeConn) Commit() error {
+	c.d.record("COMMIT")
+	return nil
+}
+
+func (c *fakeConn) Rollback() error {
+	c.d.record("ROLLBACK")
+	return nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.d.record(query)
+	switch query {
+	case "SELECT pg_advisory_lock($1)":
+		for !c.d.try(c, args[0].Value.(int64)) {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(time.Millisecond):
+			}
+		}
+	case "SELECT pg_advisory_unlock($1)":
+		c.d.unlock(c, args[0].Value.(int64))
+	}
+	return driver.RowsAffected(0), nil
+}
+
+// QueryContext returns empty result for queries other than advisory lock
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.d.record(query)
+	if query != "SELECT pg_try_advisory_lock($1)" {
+		return &boolRows{done: true}, nil
+	}
+	return &boolRows{value: c.d.try(c, args[0].Value.(int64))}, nil
+}
+
+type boolRows struct {
+	value bool
+	done  bool
+}
+
+func (r *boolRows) Columns() []string {
+	return []string{"result"}
+}
+
+func (r *boolRows) Close() error {
+	return nil
+}
+
+func (r *boolRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = r.value
+	return nil
+}
+
+var testFakeDriver = &fakeDriver{}
+
+func init() {
+	sql.Register("godb_fake", testFakeDriver)
+}
+
+type fakeConnection struct {
+	ConnectionConfig
+}
+
+func (c *fakeConnection) String() string {
+	return c.DSN(Credentials{User: c.User, Password: c.Password})
+}
+
+func (c *fakeConnection) DSN(credentials Credentials) string {
+	return credentials.User + ":" + credentials.Password
+}
+
+func (c *fakeConnection) GetDbType() string {
+	return "godb_fake"
+}
+
+func TestRotatingConnection(t *testing.T) {
+	testFakeDriver.reset(0)
+	var m sync.Mutex
+	password := "first"
+	conn := &RotatingConnection{
+		Connection: &fakeConnection{ConnectionConfig{MaxConnections: 5, MaxIdleConnections: 5}},
+		Provider: func(ctx context.Context) (Credentials, error) {
+			m.Lock()
+			defer m.Unlock()
+			return Credentials{User: "app", Password: password}, nil
+		},
+	}
+	db, err := DBO{Connection: conn}.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.DB.Close()
+	if db.Stats().Idle != 1 {
+		t.Fatal("ping connection must be idle")
+	}
+	m.Lock()
+	password = "second"
+	m.Unlock()
+	c, err := db.DB.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// idle connection is reused, no new physical connection
+	c.Close()
+	err = conn.Refresh(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.Stats().Idle != 0 {
+		t.Fatal("idle connections must be recycled after rotation")
+	}
+	c, err = db.DB.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	opened := testFakeDriver.opened()
+	if len(opened) != 2 || opened[0] != "app:first" || opened[1] != "app:second" {
+		t.Fatal("wrong opened connections", opened)
+	}
+
+	t.Run("shared", func(t *testing.T) {
+		other, err := DBO{Connection: conn}.Init()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer other.DB.Close()
+		if db.Stats().Idle != 1 || other.Stats().Idle != 1 {
+			t.Fatal("connections must be idle")
+		}
+		m.Lock()
+		password = "third"
+		m.Unlock()
+		if err = conn.Refresh(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if db.Stats().Idle != 0 || other.Stats().Idle != 0 {
+			t.Fatal("idle connections of all database objects must be recycled")
+		}
+	})
+
+	t.Run("background", func(t *testing.T) {
+		shared := &RotatingConnection{
+			Connection:      conn.Connection,
+			Provider:        conn.Provider,
+			RefreshInterval: time.Millisecond * 10,
+		}
+		db, err := DBO{Connection: shared}.Init()
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.Lock()
+		password = "fourth"
+		m.Unlock()
+		deadline := time.Now().Add(time.Second)
+		for db.Stats().Idle != 0 {
+			if time.Now().After(deadline) {
+				t.Fatal("idle connection must be recycled by background refresh")
+			}
+			time.Sleep(time.Millisecond * 5)
+		}
+		if err = db.Shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		deadline = time.Now().Add(time.Second)
+		for {
+			shared.m.Lock()
+			n := len(shared.onRotate)
+			shared.m.Unlock()
+			if n == 0 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("rotation callback must be removed on shutdown")
+			}
+			time.Sleep(time.Millisecond * 5)
+		}
+	})
+}
@@ -3,21 +3,53 @@ package godb
 import (
 	"context"
 	"database/sql"
-	"time"
 )
 
 // Init Database Object
 func (dbo DBO) Init() (*DBO, error) {
-	db, err := getDb(dbo.Connection)
+	return dbo.InitContext(context.Background())
+}
+
+// InitContext Init Database Object. Context cancels connect retries
+// Empty pool settings of connection are filled by defaults, then pool settings are validated
+func (dbo DBO) InitContext(ctx context.Context) (*DBO, error) {
+	if d, ok := dbo.Connection.(ConfigDefaults); ok {
+		d.SetDefaults()
+	}
+	if v, ok := dbo.Connection.(PoolConfig); ok {
+		if err := v.ValidatePool(); err != nil {
+			return &dbo, err
+		}
+	}
+	db, err := getDb(ctx, dbo.Connection, dbo.Options)
 	if err != nil {
 		return &dbo, err
 	}
 	dbo.DB = db
-	dbo.logMessage = make(chan string, dbo.Connection.GetMaxConnection())
-	go logger(dbo.Logger, dbo.logMessage)
+	bufferSize := dbo.Connection.GetMaxConnection()
+	if bufferSize <= 0 {
+		bufferSize = DefaultLogBufferSize
+	}
+	dbo.queryLog = newQueryLogger(dbo.Options, bufferSize)
+	dbo.state = newDboState()
+	if rc, ok := dbo.Connection.(*RotatingConnection); ok {
+		remove := rc.addOnRotate(dbo.RecycleIdleConnections)
+		if rc.RefreshInterval > 0 {
+			go rc.refresh(dbo.state, dbo.Logger, remove)
+		}
+	}
+	dbo.stmtCache = newStmtCache(dbo.Options.StatementCacheSize, func(query string) (*sql.Stmt, error) {
+		return db.PrepareContext(context.Background(), query)
+	})
 	return &dbo, nil
 }
 
+// RecycleIdleConnections close all idle connections. New connections will be opened on demand
+func (dbo *DBO) RecycleIdleConnections() {
+	dbo.DB.SetMaxIdleConns(0)
+	dbo.DB.SetMaxIdleConns(dbo.Connection.GetMaxIdleConns())
+}
+
 // ConnType get connection type
 func (dbo *DBO) ConnType() string {
 	return dbo.Connection.GetDbType()
@@ -28,8 +60,13 @@ func (dbo *DBO) Query(query string, args ...interface{}) (*sql.Rows, error) {
 	if dbo.Options.QueryProcessor != nil {
 		query = dbo.Options.QueryProcessor(query)
 	}
-	if dbo.Debug == true {
-		dbo.logMessage <- query
+	if err := dbo.state.acquire(); err != nil {
+		return nil, err
+	}
+	defer dbo.state.release()
+	dbo.logQuery(query)
+	if dbo.stmtCache.accepts(query, args) {
+		return dbo.stmtCache.query(context.Background(), nil, query, args...)
 	}
 	return dbo.DB.QueryContext(context.Background(), query, args...)
 }
@@ -39,69 +76,118 @@ func (dbo *DBO) Exec(query string, args ...interface{}) (sql.Result, error) {
 	if dbo.Options.QueryProcessor != nil {
 		query = dbo.Options.QueryProcessor(query)
 	}
-	if dbo.Debug == true {
-		dbo.logMessage <- query
+	if err := dbo.state.acquire(); err != nil {
+		return nil, err
+	}
+	defer dbo.state.release()
+	dbo.logQuery(query)
+	if dbo.stmtCache.accepts(query, args) {
+		return dbo.stmtCache.exec(context.Background(), nil, query, args...)
 	}
 	return dbo.DB.ExecContext(context.Background(), query, args...)
 }
 
-// QueryRow SQL query row
+// QueryRow SQL query row. Row contains context canceled error if database object is closed
 func (dbo *DBO) QueryRow(query string, args ...interface{}) *sql.Row {
 	if dbo.Options.QueryProcessor != nil {
 		query = dbo.Options.QueryProcessor(query)
 	}
-	if dbo.Debug == true {
-		dbo.logMessage <- query
+	if err := dbo.state.acquire(); err != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		return dbo.DB.QueryRowContext(ctx, query, args...)
+	}
+	defer dbo.state.release()
+	dbo.logQuery(query)
+	if dbo.stmtCache.accepts(query, args) {
+		if row := dbo.stmtCache.queryRow(context.Background(), nil, query, args...); row != nil {
+			return row
+		}
 	}
 	return dbo.DB.QueryRowContext(context.Background(), query, args...)
 }
 
 // Prepare statement
 func (dbo *DBO) Prepare(query string) (*SqlStmt, error) {
+	args := countArgs(query)
 	if dbo.Options.QueryProcessor != nil {
 		query = dbo.Options.QueryProcessor(query)
 	}
+	if err := dbo.state.acquire(); err != nil {
+		return nil, err
+	}
+	defer dbo.state.release()
 	stmt, err := dbo.DB.PrepareContext(context.Background(), query)
-	return &SqlStmt{Stmt: stmt, Options: dbo.Options, query: query}, err
+	return newStmt(stmt, dbo.Options, query, args, nil), err
 }
 
 // Begin transaction
 func (dbo *DBO) Begin() (*SqlTx, error) {
-	tx, err := dbo.DB.BeginTx(context.Background(), nil)
+	return dbo.BeginContext(context.Background(), nil)
+}
+
+// BeginContext begin transaction with options. Transaction is rolled back when context is done or TTL expires
+func (dbo *DBO) BeginContext(ctx context.Context, opts *TxOptions) (*SqlTx, error) {
+	if err := dbo.state.acquire(); err != nil {
+		return nil, err
+	}
+	defer dbo.state.release()
+	return dbo.begin(ctx, opts, dbo.DB.BeginTx)
+}
+
+// begin transaction with begin function of database or connection
+func (dbo *DBO) begin(ctx context.Context, opts *TxOptions, beginTx func(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)) (*SqlTx, error) {
+	ttl := dbo.Options.TransactionTTL
+	var txOptions *sql.TxOptions
+	if opts != nil {
+		if opts.TTL != 0 {
+			ttl = opts.TTL
+		}
+		txOptions = &sql.TxOptions{Isolation: opts.Isolation, ReadOnly: opts.ReadOnly}
+	}
+	transaction := newTransaction(ctx, ttl)
+	transaction.logger = dbo.Logger
+	tx, err := beginTx(transaction.ctx, txOptions)
+	if err != nil {
+		transaction.cancel()
+		return nil, err
+	}
 	stx := &SqlTx{
-		Tx:      tx,
-		Options: dbo.Options,
-		transaction: &Transaction{
-			TTL: int(dbo.Options.TransactionTTL),
-		},
-		Connection: dbo.Connection,
-	}
-	stx.delayedRollback()
-	return stx, err
-}
-
-// Delayed rollback
-func (tx *SqlTx) delayedRollback() {
-	if tx.transaction != nil && tx.transaction.TTL > 0 {
-		go func() {
-			timer := time.After(time.Duration(tx.transaction.TTL) * time.Second)
-			tx.transaction.done = make(chan struct{})
-			for {
-				select {
-				case <-tx.transaction.done:
-					close(tx.transaction.done)
-					return
-				case <-timer:
-					err := tx.rollback()
-					if err != nil {
-						tx.Logger.Println(err)
-					}
-					return
-				}
-			}
-		}()
-	}
-	return
+		Tx:          tx,
+		Options:     dbo.Options,
+		transaction: transaction,
+		Connection:  dbo.Connection,
+		state:       dbo.state,
+		stmtCache:   dbo.stmtCache,
+	}
+	err = dbo.state.addTx(stx)
+	if err != nil {
+		transaction.cancel()
+		return nil, err
+	}
+	transaction.start(func() {
+		stx.state.removeTx(stx)
+	})
+	if opts != nil && opts.StatementTimeout > 0 {
+		if err = stx.SetStatementTimeout(statementTimeout(ctx, opts.StatementTimeout)); err != nil {
+			_ = stx.Rollback()
+			return nil, err
+		}
+	}
+	return stx, nil
+}
+
+// Transaction params of transaction
+func (tx *SqlTx) Transaction() *Transaction {
+	return tx.transaction
+}
+
+// Context of transaction. Cancelled when transaction TTL expires
+func (tx *SqlTx) context() context.Context {
+	if tx.transaction == nil {
+		return context.Background()
+	}
+	return tx.transaction.ctx
 }
 
 // Commit
@@ -110,14 +196,15 @@ func (tx *SqlTx) commit() error {
 	return tx.Tx.Commit()
 }
 
-// Commit transaction
+// Commit transaction. Repeated commit is no-op
+// ErrTxExpired, ErrTxRolledBack is returned if transaction is already finished with other state
+// Errors of commit hooks are not returned, see Transaction().HookError()
 func (tx *SqlTx) Commit() error {
-	// Stop timer
-	if tx.transaction.done != nil {
-		tx.transaction.done <- struct{}{}
+	if tx.transaction == nil {
+		return tx.commit()
 	}
-	// Commit
-	return tx.commit()
+	defer tx.state.removeTx(tx)
+	return tx.transaction.finish(TxCommitted, tx.commit)
 }
 
 func (tx *SqlTx) rollback() error {
@@ -125,33 +212,35 @@ func (tx *SqlTx) rollback() error {
 	return tx.Tx.Rollback()
 }
 
-// Rollback transaction
+// Rollback transaction. Repeated rollback is no-op
+// ErrTxExpired, ErrTxCommitted is returned if transaction is already finished with other state
 func (tx *SqlTx) Rollback() error {
-	// Stop timer
-	if tx.transaction.done != nil {
-		tx.transaction.done <- struct{}{}
+	if tx.transaction == nil {
+		return tx.rollback()
 	}
-	// rollback
-	return tx.rollback()
+	defer tx.state.removeTx(tx)
+	return tx.transaction.finish(TxRolledBack, tx.rollback)
 }
 
 // Prepare Stmt
 func (tx *SqlTx) Prepare(query string) (*SqlStmt, error) {
 	tx.m.Lock()
 	defer tx.m.Unlock()
+	args := countArgs(query)
 	if tx.Options.QueryProcessor != nil {
 		query = tx.Options.QueryProcessor(query)
 	}
-	stmt, err := tx.PrepareContext(context.Background(), query)
-	return &SqlStmt{Stmt: stmt, Options: tx.Options, query: query}, err
+	query = tx.timeoutHint(query)
+	stmt, err := tx.PrepareContext(tx.context(), query)
+	return newStmt(stmt, tx.Options, query, args, tx.context()), err
 }
 
 // Stmt Get Stmt
 func (tx *SqlTx) Stmt(stmt *SqlStmt) *SqlStmt {
 	tx.m.Lock()
 	defer tx.m.Unlock()
-	stm := tx.StmtContext(context.Background(), stmt.Stmt)
-	return &SqlStmt{Stmt: stm, Options: tx.Options, query: stmt.query}
+	stm := tx.StmtContext(tx.context(), stmt.Stmt)
+	return newStmt(stm, tx.Options, stmt.query, stmt.args, tx.context())
 }
 
 // Exec Transaction
@@ -161,10 +250,12 @@ func (tx *SqlTx) Exec(query string, args ...interface{}) (sql.Result, error) {
 	if tx.Options.QueryProcessor != nil {
 		query = tx.Options.QueryProcessor(query)
 	}
-	if tx.Debug == true {
-		tx.logMessage <- query
+	query = tx.timeoutHint(query)
+	tx.logQuery(query)
+	if tx.stmtCache.accepts(query, args) {
+		return tx.stmtCache.exec(tx.context(), tx.Tx, query, args...)
 	}
-	return tx.Tx.ExecContext(context.Background(), query, args...)
+	return tx.Tx.ExecContext(tx.context(), query, args...)
 }
 
 // Query Transaction
@@ -174,10 +265,12 @@ func (tx *SqlTx) Query(query string, args ...interface{}) (*sql.Rows, error) {
 	if tx.Options.QueryProcessor != nil {
 		query = tx.Options.QueryProcessor(query)
 	}
-	if tx.Debug == true {
-		tx.logMessage <- query
+	query = tx.timeoutHint(query)
+	tx.logQuery(query)
+	if tx.stmtCache.accepts(query, args) {
+		return tx.stmtCache.query(tx.context(), tx.Tx, query, args...)
 	}
-	return tx.Tx.QueryContext(context.Background(), query, args...)
+	return tx.Tx.QueryContext(tx.context(), query, args...)
 }
 
 // QueryRow Query Row transaction
@@ -187,10 +280,14 @@ func (tx *SqlTx) QueryRow(query string, args ...interface{}) *sql.Row {
 	if tx.Options.QueryProcessor != nil {
 		query = tx.Options.QueryProcessor(query)
 	}
-	if tx.Debug == true {
-		tx.logMessage <- query
+	query = tx.timeoutHint(query)
+	tx.logQuery(query)
+	if tx.stmtCache.accepts(query, args) {
+		if row := tx.stmtCache.queryRow(tx.context(), tx.Tx, query, args...); row != nil {
+			return row
+		}
 	}
-	return tx.Tx.QueryRowContext(context.Background(), query, args...)
+	return tx.Tx.QueryRowContext(tx.context(), query, args...)
 }
 
 // ConnType get connection type
@@ -198,41 +295,47 @@ func (tx *SqlTx) ConnType() string {
 	return tx.Connection.GetDbType()
 }
 
-// Exec Stmt Exec
-func (st *SqlStmt) Exec(args ...interface{}) (sql.Result, error) {
-	st.m.Lock()
-	defer st.m.Unlock()
-	if st.Options.QueryProcessor != nil {
-		st.query = st.Options.QueryProcessor(st.query)
+// Notify send postgres notification. Listeners receive it only if transaction is committed
+func (tx *SqlTx) Notify(channel, payload string) error {
+	if tx.ConnType() != "postgres" {
+		return ErrUnsupportedDialect
 	}
-	if st.Debug == true {
-		st.logMessage <- st.query
+	_, err := tx.Exec("SELECT pg_notify($1, $2)", channel, payload)
+	return err
+}
+
+// SQL processed query of statement
+func (st *SqlStmt) SQL() string {
+	return st.query
+}
+
+// NumInput count of statement arguments
+func (st *SqlStmt) NumInput() int {
+	return st.args
+}
+
+// context of statement. Transaction statements use transaction context
+func (st *SqlStmt) context() context.Context {
+	if st.ctx == nil {
+		return context.Background()
 	}
-	return st.Stmt.ExecContext(context.Background(), args...)
+	return st.ctx
+}
+
+// Exec Stmt Exec
+func (st *SqlStmt) Exec(args ...interface{}) (sql.Result, error) {
+	st.logQuery(st.query)
+	return st.Stmt.ExecContext(st.context(), args...)
 }
 
 // Query Stmt Query
 func (st *SqlStmt) Query(args ...interface{}) (*sql.Rows, error) {
-	st.m.Lock()
-	defer st.m.Unlock()
-	if st.Options.QueryProcessor != nil {
-		st.query = st.Options.QueryProcessor(st.query)
-	}
-	if st.Debug == true {
-		st.logMessage <- st.query
-	}
-	return st.Stmt.QueryContext(context.Background(), args...)
+	st.logQuery(st.query)
+	return st.Stmt.QueryContext(st.context(), args...)
 }
 
 // QueryRow Stmt Query Row
 func (st *SqlStmt) QueryRow(args ...interface{}) *sql.Row {
-	st.m.Lock()
-	defer st.m.Unlock()
-	if st.Options.QueryProcessor != nil {
-		st.query = st.Options.QueryProcessor(st.query)
-	}
-	if st.Debug == true {
-		st.logMessage <- st.query
-	}
-	return st.Stmt.QueryRowContext(context.Background(), args...)
+	st.logQuery(st.query)
+	return st.Stmt.QueryRowContext(st.context(), args...)
 }
@@ -0,0 +1,189 @@
+package godb
+
+import (
+	"context"
+	"github.com/dimonrus/gocli"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestInitRetry(t *testing.T) {
+	logger := gocli.NewLogger(gocli.LoggerConfig{})
+	connection := &fakeConnection{ConnectionConfig{Host: "fake", Name: "fake", User: "fake", MaxConnections: 2}}
+	t.Run("retry", func(t *testing.T) {
+		testFakeDriver.reset(2)
+		db, err := DBO{
+			Options: Options{
+				Logger:       logger,
+				ConnectRetry: ConnectRetry{Attempts: 3, Backoff: time.Millisecond},
+			},
+			Connection: connection,
+		}.Init()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.DB.Close()
+		if len(testFakeDriver.opened()) != 1 {
+			t.Fatal("must be connected on third attempt")
+		}
+	})
+	t.Run("attempts_exceeded", func(t *testing.T) {
+		testFakeDriver.reset(3)
+		_, err := DBO{
+			Options: Options{
+				Logger:       logger,
+				ConnectRetry: ConnectRetry{Attempts: 3, Backoff: time.Millisecond},
+			},
+			Connection: connection,
+		}.Init()
+		if err == nil {
+			t.Fatal("must fail after 3 attempts")
+		}
+	})
+	t.Run("cancel", func(t *testing.T) {
+		testFakeDriver.reset(1000)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+		defer cancel()
+		start := time.Now()
+		_, err := DBO{
+			Options: Options{
+				Logger:       logger,
+				ConnectRetry: ConnectRetry{Attempts: -1, Backoff: time.Millisecond * 10, MaxBackoff: time.Millisecond * 20},
+			},
+			Connection: connection,
+		}.InitContext(ctx)
+		if err == nil {
+			t.Fatal("must fail on context cancel")
+		}
+		if time.Since(start) > time.Second {
+			t.Fatal("retry must stop on context cancel")
+		}
+	})
+	t.Run("lazy", func(t *testing.T) {
+		testFakeDriver.reset(1)
+		db, err := DBO{
+			Options:    Options{LazyConnect: true},
+			Connection: connection,
+		}.Init()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.DB.Close()
+		if len(testFakeDriver.opened()) != 0 {
+			t.Fatal("lazy connect must not open connections")
+		}
+	})
+}
+
+func TestSqlStmt(t *testing.T) {
+	lg := newTestLogger()
+	db := initSqlite(t, Options{
+		Debug:   true,
+		Logger:  lg,
+		LogSync: true,
+		QueryProcessor: func(query string) string {
+			return query + " /* processed */"
+		},
+	})
+	_, err := db.Exec("CREATE TABLE stmt_test (id INTEGER, name TEXT)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Run("processed_once", func(t *testing.T) {
+		stmt, err := db.Prepare("INSERT INTO stmt_test (id, name) VALUES (?, ?)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stmt.Close()
+		for i := 0; i < 3; i++ {
+			if _, err = stmt.Exec(i, "name"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if stmt.SQL() != "INSERT INTO stmt_test (id, name) VALUES (?, ?) /* processed */" {
+			t.Fatal("wrong statement query", stmt.SQL())
+		}
+		if stmt.NumInput() != 2 {
+			t.Fatal("wrong args count", stmt.NumInput())
+		}
+		lg.m.Lock()
+		last := lg.messages[len(lg.messages)-1]
+		lg.m.Unlock()
+		if last != stmt.SQL() {
+			t.Fatal("wrong logged query", last)
+		}
+	})
+	t.Run("concurrent", func(t *testing.T) {
+		stmt, err := db.Prepare("SELECT count(*) FROM stmt_test WHERE id >= ?")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stmt.Close()
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				var count int
+				if err := stmt.QueryRow(0).Scan(&count); err != nil || count != 3 {
+					t.Error("wrong count", count, err)
+				}
+			}()
+		}
+		wg.Wait()
+		if stmt.SQL() != "SELECT count(*) FROM stmt_test WHERE id >= ? /* processed */" {
+			t.Fatal("wrong statement query", stmt.SQL())
+		}
+	})
+	t.Run("transaction", func(t *testing.T) {
+		stmt, err := db.Prepare("SELECT count(*) FROM stmt_test WHERE id = ?")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stmt.Close()
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tx.Rollback()
+		txStmt := tx.Stmt(stmt)
+		if txStmt.SQL() != stmt.SQL() {
+			t.Fatal("transaction statement must not be processed again", txStmt.SQL())
+		}
+		if txStmt.NumInput() != 1 {
+			t.Fatal("wrong transaction statement args count", txStmt.NumInput())
+		}
+		var count int
+		if err = txStmt.QueryRow(1).Scan(&count); err != nil || count != 1 {
+			t.Fatal("wrong count", count, err)
+		}
+	})
+}
+
+func TestSqlStmt_NumInput(t *testing.T) {
+	db := initSqlite(t, Options{QueryProcessor: PreparePositionalArgsQuery})
+	stmt, err := db.Prepare("SELECT ??")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stmt.Close()
+	if stmt.SQL() != "SELECT ?" {
+		t.Fatal("wrong statement query", stmt.SQL())
+	}
+	if stmt.NumInput() != 0 {
+		t.Fatal("escaped ?? must not be counted after processing", stmt.NumInput())
+	}
+}
+
+func TestSqlTx_Notify(t *testing.T) {
+	db := initSqlite(t, Options{})
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if err = tx.Notify("cache", "users:1"); err != ErrUnsupportedDialect {
+		t.Fatal("sqlite has no notifications", err)
+	}
+}
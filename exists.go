@@ -0,0 +1,132 @@
+package godb
+
+import (
+	"errors"
+	"github.com/dimonrus/godb/v2/internal/dialect"
+	"strings"
+)
+
+// ErrUnsupportedDialect queryer connection type is not supported
+var ErrUnsupportedDialect = errors.New("unsupported database type")
+
+// Postgres relation filter. Relation must be visible in search_path if schema is empty
+func pgRelation(schema string, args []interface{}) (string, []interface{}) {
+	if schema == "" {
+		return "pg_catalog.pg_table_is_visible(c.oid)", args
+	}
+	args = append(args, schema)
+	return "n.nspname = " + positionalArgs[len(args)], args
+}
+
+// Run exists query
+func exists(q Queryer, query string, args ...interface{}) (bool, error) {
+	var result bool
+	err := q.QueryRow("SELECT EXISTS ("+query+")", args...).Scan(&result)
+	return result, err
+}
+
+// TableExists check if table or view exists. Current schema or database is used if schema is empty
+func TableExists(q Queryer, table, schema string) (bool, error) {
+	switch Dialect(q) {
+	case "postgres":
+		filter, args := pgRelation(schema, []interface{}{table})
+		return exists(q, `SELECT 1 FROM pg_catalog.pg_class c JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+WHERE c.relname = $1 AND c.relkind IN ('r', 'p', 'v', 'm', 'f') AND `+filter, args...)
+	case "mysql":
+		return exists(q, `SELECT 1 FROM information_schema.tables
+WHERE table_schema = `+dialect.MysqlSchema+` AND table_name = ?`, schema, table)
+	case "sqlite3":
+		return exists(q, `SELECT 1 FROM `+dialect.QuoteIdent(dialect.SqliteSchema(schema))+`.sqlite_master
+WHERE type IN ('table', 'view') AND name = ?`, table)
+	}
+	return false, ErrUnsupportedDialect
+}
+
+// ColumnExists check if table column exists. Current schema or database is used if schema is empty
+func ColumnExists(q Queryer, table, column, schema string) (bool, error) {
+	switch Dialect(q) {
+	case "postgres":
+		filter, args := pgRelation(schema, []interface{}{table, column})
+		return exists(q, `SELECT 1 FROM pg_catalog.pg_attribute a
+JOIN pg_catalog.pg_class c ON c.oid = a.attrelid JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+WHERE c.relname = $1 AND a.attname = $2 AND a.attnum > 0 AND NOT a.attisdropped AND `+filter, args...)
+	case "mysql":
+		return exists(q, `SELECT 1 FROM information_schema.columns
+WHERE table_schema = `+dialect.MysqlSchema+` AND table_name = ? AND column_name = ?`, schema, table, column)
+	case "sqlite3":
+		return exists(q, `SELECT 1 FROM pragma_table_info(?, ?) WHERE name = ?`,
+			table, dialect.SqliteSchema(schema), column)
+	}
+	return false, ErrUnsupportedDialect
+}
+
+// IndexExists check if index of table exists. Current schema or database is used if schema is empty
+func IndexExists(q Queryer, table, index, schema string) (bool, error) {
+	switch Dialect(q) {
+	case "postgres":
+		filter, args := pgRelation(schema, []interface{}{table, index})
+		return exists(q, `SELECT 1 FROM pg_catalog.pg_index x
+JOIN pg_catalog.pg_class i ON i.oid = x.indexrelid
+JOIN pg_catalog.pg_class c ON c.oid = x.indrelid JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+WHERE c.relname = $1 AND i.relname = $2 AND `+filter, args...)
+	case "mysql":
+		return exists(q, `SELECT 1 FROM information_schema.statistics
+WHERE table_schema = `+dialect.MysqlSchema+` AND table_name = ? AND index_name = ?`, schema, table, index)
+	case "sqlite3":
+		return exists(q, `SELECT 1 FROM `+dialect.QuoteIdent(dialect.SqliteSchema(schema))+`.sqlite_master
+WHERE type = 'index' AND tbl_name = ? AND name = ?`, table, index)
+	}
+	return false, ErrUnsupportedDialect
+}
+
+// SchemaExists check if schema exists. Database is checked for mysql, attached database for sqlite
+func SchemaExists(q Queryer, schema string) (bool, error) {
+	switch Dialect(q) {
+	case "postgres":
+		return exists(q, `SELECT 1 FROM pg_catalog.pg_namespace WHERE nspname = $1`, schema)
+	case "mysql":
+		return exists(q, `SELECT 1 FROM information_schema.schemata WHERE schema_name = ?`, schema)
+	case "sqlite3":
+		return exists(q, `SELECT 1 FROM pragma_database_list WHERE name = ?`, schema)
+	}
+	return false, ErrUnsupportedDialect
+}
+
+// SequenceExists check if sequence exists. Current schema or database is used if schema is empty
+// Sequences are supported by MariaDB only, sqlite has no sequences
+func SequenceExists(q Queryer, sequence, schema string) (bool, error) {
+	switch Dialect(q) {
+	case "postgres":
+		filter, args := pgRelation(schema, []interface{}{sequence})
+		return exists(q, `SELECT 1 FROM pg_catalog.pg_class c JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+WHERE c.relname = $1 AND c.relkind = 'S' AND `+filter, args...)
+	case "mysql":
+		return exists(q, `SELECT 1 FROM information_schema.tables
+WHERE table_schema = `+dialect.MysqlSchema+` AND table_name = ? AND table_type = 'SEQUENCE'`, schema, sequence)
+	case "sqlite3":
+		return false, nil
+	}
+	return false, ErrUnsupportedDialect
+}
+
+// FunctionExists check if function or procedure exists. Current schema or database is used if schema is empty
+// Schema is ignored for sqlite, built-in and registered functions are checked
+func FunctionExists(q Queryer, function, schema string) (bool, error) {
+	switch Dialect(q) {
+	case "postgres":
+		args := []interface{}{function}
+		filter := "pg_catalog.pg_function_is_visible(p.oid)"
+		if schema != "" {
+			args = append(args, schema)
+			filter = "n.nspname = $2"
+		}
+		return exists(q, `SELECT 1 FROM pg_catalog.pg_proc p JOIN pg_catalog.pg_namespace n ON n.oid = p.pronamespace
+WHERE p.proname = $1 AND `+filter, args...)
+	case "mysql":
+		return exists(q, `SELECT 1 FROM information_schema.routines
+WHERE routine_schema = `+dialect.MysqlSchema+` AND routine_name = ?`, schema, function)
+	case "sqlite3":
+		return exists(q, `SELECT 1 FROM pragma_function_list WHERE name = ?`, strings.ToLower(function))
+	}
+	return false, ErrUnsupportedDialect
+}
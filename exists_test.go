@@ -0,0 +1,59 @@
+package godb
+
+import "testing"
+
+func TestExists(t *testing.T) {
+	db := initSqlite(t, Options{})
+	for _, query := range []string{
+		"CREATE TABLE exists_test (id INTEGER PRIMARY KEY, name TEXT)",
+		"CREATE INDEX exists_test_name ON exists_test (name)",
+	} {
+		if _, err := db.Exec(query); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check := func(name string, expected bool, ok bool, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		if ok != expected {
+			t.Fatal(name, "must be", expected)
+		}
+	}
+	ok, err := TableExists(db, "exists_test", "")
+	check("table", true, ok, err)
+	ok, err = TableExists(db, "exists_test", "main")
+	check("table in schema", true, ok, err)
+	ok, err = TableExists(db, "exists_test' OR '1' = '1", "")
+	check("injected table", false, ok, err)
+	ok, err = ColumnExists(db, "exists_test", "name", "")
+	check("column", true, ok, err)
+	ok, err = ColumnExists(db, "exists_test", "age", "")
+	check("missing column", false, ok, err)
+	ok, err = IndexExists(db, "exists_test", "exists_test_name", "")
+	check("index", true, ok, err)
+	ok, err = IndexExists(db, "other", "exists_test_name", "")
+	check("index of other table", false, ok, err)
+	ok, err = SchemaExists(db, "main")
+	check("schema", true, ok, err)
+	ok, err = SchemaExists(db, "other")
+	check("missing schema", false, ok, err)
+	ok, err = SequenceExists(db, "exists_test_seq", "")
+	check("sequence", false, ok, err)
+	ok, err = FunctionExists(db, "LENGTH", "")
+	check("function", true, ok, err)
+	ok, err = FunctionExists(db, "no_such_function", "")
+	check("missing function", false, ok, err)
+	_, err = TableExists(db, "exists_test", `other"`)
+	if err == nil {
+		t.Fatal("unknown database must fail")
+	}
+	if !IsTableExists(db, "exists_test", "") || IsTableExists(db, "exists_test", `other"`) {
+		t.Fatal("wrong IsTableExists result")
+	}
+	var q struct{ Queryer }
+	if _, err = TableExists(q, "exists_test", ""); err != ErrUnsupportedDialect {
+		t.Fatal("queryer without connection type must not be supported", err)
+	}
+}
@@ -4,10 +4,12 @@ go 1.18
 
 require (
 	github.com/dimonrus/gocli v0.13.1
-	github.com/dimonrus/gohelp v1.7.0
+	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.17
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
+	github.com/dimonrus/gohelp v1.7.0 // indirect
 	github.com/dimonrus/porterr v1.13.1 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,114 @@
+package godb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// DefaultHealthTimeout health check timeout if it is not set
+const DefaultHealthTimeout = time.Second * 5
+
+// HealthReport database health report
+type HealthReport struct {
+	// Database responds to ping
+	Healthy bool `json:"healthy"`
+	// Database accepts writes
+	Writable bool `json:"writable"`
+	// Ping or check error
+	Error string `json:"error,omitempty"`
+	// Ping latency
+	Latency time.Duration `json:"latency"`
+	// Server version
+	Version string `json:"version,omitempty"`
+	// Server is replica in recovery mode
+	InRecovery bool `json:"inRecovery"`
+	// Server is in read only mode
+	ReadOnly bool `json:"readOnly"`
+	// Connection pool statistics
+	Pool sql.DBStats `json:"pool"`
+	// Transactions count in transaction pool
+	Transactions int `json:"transactions"`
+	// Age of the oldest transaction in transaction pool
+	OldestTransactionAge time.Duration `json:"oldestTransactionAge"`
+	// Time of check
+	CheckedAt time.Time `json:"checkedAt"`
+}
+
+// Health check database and return report. Error is returned when database does not respond to ping
+func (dbo *DBO) Health(ctx context.Context) (*HealthReport, error) {
+	report := &HealthReport{CheckedAt: time.Now()}
+	if dbo.TransactionPool != nil {
+		report.Transactions = dbo.TransactionPool.Count()
+		report.OldestTransactionAge = dbo.TransactionPool.OldestAge()
+	}
+	start := time.Now()
+	err := dbo.DB.PingContext(ctx)
+	report.Latency = time.Since(start)
+	report.Pool = dbo.DB.Stats()
+	if err != nil {
+		report.Error = err.Error()
+		return report, err
+	}
+	report.Healthy = true
+	switch dbo.ConnType() {
+	case "postgres":
+		err = dbo.DB.QueryRowContext(ctx, "SELECT current_setting('server_version'), pg_is_in_recovery(), current_setting('transaction_read_only') = 'on'").
+			Scan(&report.Version, &report.InRecovery, &report.ReadOnly)
+	case "mysql":
+		err = dbo.DB.QueryRowContext(ctx, "SELECT VERSION(), @@global.read_only = 1").
+			Scan(&report.Version, &report.ReadOnly)
+	case "sqlite3":
+		err = dbo.DB.QueryRowContext(ctx, "SELECT sqlite_version()").Scan(&report.Version)
+	}
+	if err != nil {
+		// writable state is unknown
+		report.Error = err.Error()
+		return report, nil
+	}
+	report.Writable = !report.InRecovery && !report.ReadOnly
+	return report, nil
+}
+
+// HealthHandler http handler serves database health report as json
+type HealthHandler struct {
+	// Database object
+	DBO *DBO
+	// Check timeout
+	Timeout time.Duration
+	// Respond with unavailable status when database is in recovery, read only or state check failed
+	RequireWritable bool
+}
+
+// ServeHTTP serve health report
+func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = DefaultHealthTimeout
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+	report, err := h.DBO.Health(ctx)
+	status := http.StatusOK
+	if err != nil || (h.RequireWritable && (!report.Writable || report.Error != "")) {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		_ = json.NewEncoder(w).Encode(report)
+	}
+}
+
+// HealthHandler create liveness handler
+func (dbo *DBO) HealthHandler() http.Handler {
+	return &HealthHandler{DBO: dbo}
+}
+
+// ReadinessHandler create readiness handler. Database must be writable
+func (dbo *DBO) ReadinessHandler() http.Handler {
+	return &HealthHandler{DBO: dbo, RequireWritable: true}
+}
@@ -0,0 +1,92 @@
+package godb
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealth(t *testing.T) {
+	db := initSqlite(t, Options{})
+	db.TransactionPool = NewTransactionPool()
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.TransactionPool.Set(GenTransactionId(), tx)
+	defer tx.Rollback()
+	time.Sleep(time.Millisecond * 10)
+
+	t.Run("report", func(t *testing.T) {
+		report, err := db.Health(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !report.Healthy || !report.Writable || report.Version == "" {
+			t.Fatal("wrong report", report)
+		}
+		if report.Transactions != 1 || report.OldestTransactionAge < time.Millisecond*10 {
+			t.Fatal("wrong transactions in report", report.Transactions, report.OldestTransactionAge)
+		}
+	})
+
+	t.Run("handler", func(t *testing.T) {
+		server := httptest.NewServer(db.ReadinessHandler())
+		defer server.Close()
+		resp, err := http.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatal("wrong status", resp.StatusCode)
+		}
+		report := HealthReport{}
+		err = json.NewDecoder(resp.Body).Decode(&report)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !report.Healthy || report.Pool.MaxOpenConnections != 10 {
+			t.Fatal("wrong report", report)
+		}
+	})
+
+	t.Run("unavailable", func(t *testing.T) {
+		closed := initSqlite(t, Options{})
+		closed.DB.Close()
+		server := httptest.NewServer(closed.HealthHandler())
+		defer server.Close()
+		resp, err := http.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			t.Fatal("wrong status", resp.StatusCode)
+		}
+	})
+
+	t.Run("check_failed", func(t *testing.T) {
+		// fake postgres responds to ping but fails recovery check
+		failed := &DBO{DB: sql.OpenDB(testLockDriver), Connection: &fakeDbConnection{}}
+		defer failed.DB.Close()
+		report, err := failed.Health(context.Background())
+		if err != nil || !report.Healthy || report.Writable || report.Error == "" {
+			t.Fatal("writable state must be unknown", report, err)
+		}
+		server := httptest.NewServer(failed.ReadinessHandler())
+		defer server.Close()
+		resp, err := http.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			t.Fatal("wrong status", resp.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,123 @@
+package godb
+
+import (
+	"fmt"
+	"strings"
+)
+
+// HookError errors of transaction hooks. Hooks do not affect transaction outcome
+type HookError struct {
+	// Errors returned by hooks or recovered from their panics
+	Errors []error
+}
+
+// Error message
+func (e *HookError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return "transaction hooks failed: " + strings.Join(messages, "; ")
+}
+
+// OnCommit add hook called after transaction is committed
+func (tx *SqlTx) OnCommit(fn func()) {
+	tx.AfterCommit(func() error {
+		fn()
+		return nil
+	})
+}
+
+// AfterCommit add hook called after transaction is committed
+// Errors do not affect result of Commit, they are logged and available with Transaction().HookError()
+func (tx *SqlTx) AfterCommit(fn func() error) {
+	t := tx.transaction
+	t.m.Lock()
+	state := t.state
+	if state == TxActive {
+		t.commitHooks = append(t.commitHooks, fn)
+	}
+	t.m.Unlock()
+	if state == TxCommitted {
+		if err := t.call(fn); err != nil {
+			t.report([]error{err})
+		}
+	}
+}
+
+// OnRollback add hook called after transaction is rolled back or expired
+// Hook receives reason of rollback: nil for explicit rollback, ErrTxExpired for TTL expiry, context error if context
+// of begin is done or commit error
+func (tx *SqlTx) OnRollback(fn func(err error)) {
+	t := tx.transaction
+	t.m.Lock()
+	state, reason := t.state, t.reason
+	if state == TxActive {
+		t.rollbackHooks = append(t.rollbackHooks, fn)
+	}
+	t.m.Unlock()
+	if state == TxRolledBack || state == TxExpired {
+		err := t.call(func() error {
+			fn(reason)
+			return nil
+		})
+		if err != nil {
+			t.report([]error{err})
+		}
+	}
+}
+
+// runHooks call hooks of finished transaction in registration order. Errors are logged and saved
+func (t *Transaction) runHooks() {
+	t.m.Lock()
+	state, reason := t.state, t.reason
+	commitHooks, rollbackHooks := t.commitHooks, t.rollbackHooks
+	t.commitHooks, t.rollbackHooks = nil, nil
+	t.m.Unlock()
+	var errs []error
+	if state == TxCommitted {
+		for _, fn := range commitHooks {
+			if err := t.call(fn); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	} else {
+		for _, fn := range rollbackHooks {
+			err := t.call(func() error {
+				fn(reason)
+				return nil
+			})
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		t.report(errs)
+	}
+}
+
+// report save hook errors and log them once
+func (t *Transaction) report(errs []error) {
+	t.m.Lock()
+	e := &HookError{}
+	if t.hookErr != nil {
+		e.Errors = append(e.Errors, t.hookErr.Errors...)
+	}
+	e.Errors = append(e.Errors, errs...)
+	t.hookErr = e
+	t.m.Unlock()
+	if t.logger != nil {
+		t.logger.Errorln((&HookError{Errors: errs}).Error())
+	}
+}
+
+// call hook and recover its panic
+func (t *Transaction) call(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("transaction hook panic: %v", r)
+		}
+	}()
+	return fn()
+}
@@ -0,0 +1,134 @@
+package godb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTransactionHooks(t *testing.T) {
+	db := initSqlite(t, Options{})
+	t.Run("commit", func(t *testing.T) {
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var calls []string
+		tx.OnCommit(func() {
+			calls = append(calls, "first")
+		})
+		tx.AfterCommit(func() error {
+			calls = append(calls, "second")
+			return errors.New("publish failed")
+		})
+		tx.OnCommit(func() {
+			panic("cache is down")
+		})
+		tx.OnCommit(func() {
+			calls = append(calls, "fourth")
+		})
+		tx.OnRollback(func(err error) {
+			t.Fatal("rollback hook must not be called")
+		})
+		if err = tx.Commit(); err != nil {
+			t.Fatal("hook errors must not fail commit", err)
+		}
+		if e := tx.Transaction().HookError(); e == nil || len(e.Errors) != 2 {
+			t.Fatal("hook errors must be aggregated", e)
+		}
+		if len(calls) != 3 || calls[0] != "first" || calls[1] != "second" || calls[2] != "fourth" {
+			t.Fatal("hooks must be called in registration order", calls)
+		}
+		if tx.Transaction().State() != TxCommitted {
+			t.Fatal("transaction must be committed")
+		}
+		if err = tx.Commit(); err != nil {
+			t.Fatal("hooks must not be called twice", err)
+		}
+	})
+	t.Run("rollback", func(t *testing.T) {
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var reason = errors.New("not called")
+		tx.OnRollback(func(err error) {
+			reason = err
+		})
+		tx.OnCommit(func() {
+			t.Fatal("commit hook must not be called")
+		})
+		if err = tx.Rollback(); err != nil {
+			t.Fatal(err)
+		}
+		if reason != nil {
+			t.Fatal("explicit rollback reason must be nil", reason)
+		}
+	})
+	t.Run("expired", func(t *testing.T) {
+		tx, err := db.BeginContext(context.Background(), &TxOptions{TTL: time.Millisecond * 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		reason := make(chan error, 1)
+		tx.OnRollback(func(err error) {
+			reason <- err
+		})
+		select {
+		case err = <-reason:
+			if err != ErrTxExpired {
+				t.Fatal("wrong reason", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("rollback hook must be called on expiry")
+		}
+		var late error
+		tx.OnRollback(func(err error) {
+			late = err
+		})
+		if late != ErrTxExpired {
+			t.Fatal("hook registered after outcome must be called immediately", late)
+		}
+	})
+	t.Run("logged", func(t *testing.T) {
+		logger := errorLogger{newTestLogger()}
+		db := initSqlite(t, Options{Logger: logger})
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		tx.AfterCommit(func() error {
+			return errors.New("publish failed")
+		})
+		if err = tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		if logger.count() != 1 || logger.messages[0] != "transaction hooks failed: publish failed" {
+			t.Fatal("hook error must be logged", logger.messages)
+		}
+	})
+	t.Run("panic_logged_once", func(t *testing.T) {
+		logger := errorLogger{newTestLogger()}
+		db := initSqlite(t, Options{Logger: logger})
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		tx.OnCommit(func() {
+			panic("cache is down")
+		})
+		if err = tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		if logger.count() != 1 || logger.messages[0] != "transaction hooks failed: transaction hook panic: cache is down" {
+			t.Fatal("hook panic must be logged once", logger.messages)
+		}
+		tx.AfterCommit(func() error {
+			return errors.New("publish failed")
+		})
+		if logger.count() != 2 || len(tx.Transaction().HookError().Errors) != 2 {
+			t.Fatal("late hook error must be logged and saved", logger.messages)
+		}
+	})
+}
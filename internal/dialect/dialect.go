@@ -0,0 +1,20 @@
+// Package dialect helpers of dialect specific queries shared by godb packages
+package dialect
+
+import "strings"
+
+// MysqlSchema mysql schema filter. Current database is used if bound schema is empty
+const MysqlSchema = "COALESCE(NULLIF(?, ''), DATABASE())"
+
+// SqliteSchema sqlite database name. Main database if schema is empty
+func SqliteSchema(schema string) string {
+	if schema == "" {
+		return "main"
+	}
+	return schema
+}
+
+// QuoteIdent quote identifier with double quotes
+func QuoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
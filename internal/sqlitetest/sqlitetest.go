@@ -0,0 +1,77 @@
+// Package sqlitetest sqlite database for tests of subpackages
+package sqlitetest
+
+import (
+	"github.com/dimonrus/gocli"
+	"github.com/dimonrus/godb/v2"
+	_ "github.com/mattn/go-sqlite3"
+	"path/filepath"
+	"testing"
+)
+
+// Connection sqlite database file connection
+type Connection struct {
+	// Database file path
+	Path string
+	// Maximum open connections. Default is 10
+	MaxConnections int
+}
+
+// String connection string
+func (c *Connection) String() string {
+	return c.Path + "?_busy_timeout=5000"
+}
+
+// GetDbType database type
+func (c *Connection) GetDbType() string {
+	return "sqlite3"
+}
+
+// GetMaxConnection maximum open connections
+func (c *Connection) GetMaxConnection() int {
+	if c.MaxConnections > 0 {
+		return c.MaxConnections
+	}
+	return 10
+}
+
+// GetMaxIdleConns maximum idle connections
+func (c *Connection) GetMaxIdleConns() int {
+	if c.MaxConnections > 0 && c.MaxConnections < 5 {
+		return c.MaxConnections
+	}
+	return 5
+}
+
+// GetConnMaxLifetime connection lifetime in seconds
+func (c *Connection) GetConnMaxLifetime() int {
+	return 50
+}
+
+// New connection to database file in temporary directory of test
+func New(t testing.TB) *Connection {
+	return &Connection{Path: filepath.Join(t.TempDir(), "test.db")}
+}
+
+// Init database object with new connection. Database is closed on test cleanup
+func Init(t testing.TB, options godb.Options) *godb.DBO {
+	return InitConnection(t, New(t), options)
+}
+
+// InitConnection init database object with connection. Database is closed on test cleanup
+func InitConnection(t testing.TB, connection *Connection, options godb.Options) *godb.DBO {
+	if options.Logger == nil {
+		options.Logger = gocli.NewLogger(gocli.LoggerConfig{})
+	}
+	db, err := godb.DBO{
+		Options:    options,
+		Connection: connection,
+	}.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.DB.Close()
+	})
+	return db
+}
@@ -0,0 +1,244 @@
+package introspect
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/dimonrus/godb/v2"
+)
+
+// ErrUnsupportedDialect dialect has no inspector
+var ErrUnsupportedDialect = errors.New("introspect: unsupported dialect")
+
+// Table table or view
+type Table struct {
+	// Table schema
+	Schema string
+	// Table name
+	Name string
+	// Table is view
+	View bool
+}
+
+// Column table column
+type Column struct {
+	// Column name
+	Name string
+	// Column type as declared in database
+	Type string
+	// Column accepts null values
+	Nullable bool
+	// Default value expression. Nil if column has no default
+	Default *string
+	// Position of column in table starting from 1
+	Position int
+}
+
+// PrimaryKey table primary key
+type PrimaryKey struct {
+	// Constraint name. Empty if database has no constraint names
+	Name string
+	// Key columns in key order
+	Columns []string
+}
+
+// ForeignKey table foreign key
+type ForeignKey struct {
+	// Constraint name. Empty if database has no constraint names
+	Name string
+	// Key columns in key order
+	Columns []string
+	// Referenced table schema
+	RefSchema string
+	// Referenced table
+	RefTable string
+	// Referenced columns in key order
+	RefColumns []string
+	// Action on update of referenced row
+	OnUpdate string
+	// Action on delete of referenced row
+	OnDelete string
+}
+
+// Index table index
+type Index struct {
+	// Index name
+	Name string
+	// Index columns or expressions in index order
+	Columns []string
+	// Index is unique
+	Unique bool
+	// Index of primary key
+	Primary bool
+}
+
+// Check table check constraint
+type Check struct {
+	// Constraint name. Empty if constraint has no name
+	Name string
+	// Check expression
+	Expression string
+}
+
+// Inspector database schema metadata. Empty schema means current schema of connection
+type Inspector interface {
+	// Schemas list of user schemas
+	Schemas() ([]string, error)
+	// Tables list of tables in schema
+	Tables(schema string) ([]Table, error)
+	// Views list of views in schema
+	Views(schema string) ([]Table, error)
+	// Columns list of table columns ordered by position
+	Columns(schema, table string) ([]Column, error)
+	// PrimaryKey primary key of table. Nil if table has no primary key
+	PrimaryKey(schema, table string) (*PrimaryKey, error)
+	// ForeignKeys list of table foreign keys
+	ForeignKeys(schema, table string) ([]ForeignKey, error)
+	// Indexes list of table indexes
+	Indexes(schema, table string) ([]Index, error)
+	// Checks list of table check constraints
+	Checks(schema, table string) ([]Check, error)
+}
+
+// New inspector for connection type of queryer
+func New(q godb.Queryer) (Inspector, error) {
+	switch godb.Dialect(q) {
+	case "postgres":
+		return &postgres{q: q}, nil
+	case "mysql":
+		return &mysql{q: q}, nil
+	case "sqlite3":
+		return &sqlite{q: q}, nil
+	}
+	return nil, ErrUnsupportedDialect
+}
+
+// fetch run query and call scan for each row
+func fetch(q godb.Queryer, query string, scan func(rows *sql.Rows) error, args ...interface{}) error {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err = scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// names fetch single string column
+func names(q godb.Queryer, query string, args ...interface{}) ([]string, error) {
+	var result []string
+	err := fetch(q, query, func(rows *sql.Rows) error {
+		var value string
+		err := rows.Scan(&value)
+		result = append(result, value)
+		return err
+	}, args...)
+	return result, err
+}
+
+// tables fetch schema, name rows
+func tables(q godb.Queryer, view bool, query string, args ...interface{}) ([]Table, error) {
+	var result []Table
+	err := fetch(q, query, func(rows *sql.Rows) error {
+		t := Table{View: view}
+		err := rows.Scan(&t.Schema, &t.Name)
+		result = append(result, t)
+		return err
+	}, args...)
+	return result, err
+}
+
+// columns fetch name, type, nullable, default, position rows
+func columns(q godb.Queryer, query string, args ...interface{}) ([]Column, error) {
+	var result []Column
+	err := fetch(q, query, func(rows *sql.Rows) error {
+		var c Column
+		var def sql.NullString
+		err := rows.Scan(&c.Name, &c.Type, &c.Nullable, &def, &c.Position)
+		if def.Valid {
+			c.Default = &def.String
+		}
+		result = append(result, c)
+		return err
+	}, args...)
+	return result, err
+}
+
+// primaryKey fetch constraint name, column rows in key order
+func primaryKey(q godb.Queryer, query string, args ...interface{}) (*PrimaryKey, error) {
+	var pk *PrimaryKey
+	err := fetch(q, query, func(rows *sql.Rows) error {
+		var name, column string
+		if err := rows.Scan(&name, &column); err != nil {
+			return err
+		}
+		if pk == nil {
+			pk = &PrimaryKey{Name: name}
+		}
+		pk.Columns = append(pk.Columns, column)
+		return nil
+	}, args...)
+	return pk, err
+}
+
+// foreignKeys fetch key, name, column, ref schema, ref table, ref column, on update, on delete rows ordered by key
+// Action converts database action code. Action is used as is if converter is nil
+func foreignKeys(q godb.Queryer, action func(string) string, query string, args ...interface{}) ([]ForeignKey, error) {
+	var result []ForeignKey
+	var last string
+	err := fetch(q, query, func(rows *sql.Rows) error {
+		var key, column, refColumn, onUpdate, onDelete string
+		var fk ForeignKey
+		err := rows.Scan(&key, &fk.Name, &column, &fk.RefSchema, &fk.RefTable, &refColumn, &onUpdate, &onDelete)
+		if err != nil {
+			return err
+		}
+		if len(result) == 0 || key != last {
+			if action != nil {
+				onUpdate, onDelete = action(onUpdate), action(onDelete)
+			}
+			fk.OnUpdate, fk.OnDelete = onUpdate, onDelete
+			result = append(result, fk)
+			last = key
+		}
+		fk = result[len(result)-1]
+		fk.Columns = append(fk.Columns, column)
+		fk.RefColumns = append(fk.RefColumns, refColumn)
+		result[len(result)-1] = fk
+		return nil
+	}, args...)
+	return result, err
+}
+
+// indexes fetch name, unique, primary, column rows ordered by index
+func indexes(q godb.Queryer, query string, args ...interface{}) ([]Index, error) {
+	var result []Index
+	err := fetch(q, query, func(rows *sql.Rows) error {
+		var index Index
+		var column string
+		if err := rows.Scan(&index.Name, &index.Unique, &index.Primary, &column); err != nil {
+			return err
+		}
+		if len(result) == 0 || result[len(result)-1].Name != index.Name {
+			result = append(result, index)
+		}
+		result[len(result)-1].Columns = append(result[len(result)-1].Columns, column)
+		return nil
+	}, args...)
+	return result, err
+}
+
+// checks fetch name, expression rows
+func checks(q godb.Queryer, query string, args ...interface{}) ([]Check, error) {
+	var result []Check
+	err := fetch(q, query, func(rows *sql.Rows) error {
+		var c Check
+		err := rows.Scan(&c.Name, &c.Expression)
+		result = append(result, c)
+		return err
+	}, args...)
+	return result, err
+}
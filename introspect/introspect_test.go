@@ -0,0 +1,118 @@
+package introspect
+
+import (
+	"github.com/dimonrus/godb/v2"
+	"github.com/dimonrus/godb/v2/internal/sqlitetest"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var schema = []string{
+	`CREATE TABLE author (id INTEGER PRIMARY KEY, name TEXT NOT NULL)`,
+	`CREATE TABLE book (
+		author_id INTEGER NOT NULL REFERENCES author ON DELETE CASCADE,
+		number INTEGER NOT NULL,
+		title TEXT NOT NULL DEFAULT 'untitled',
+		price REAL CHECK (price >= 0), -- "price" CHECK (fake)
+		CONSTRAINT "book pk" PRIMARY KEY (author_id, number),
+		CONSTRAINT title_length CHECK (length(title) > 0 AND title <> ')')
+	)`,
+	`CREATE UNIQUE INDEX book_title ON book (title, author_id)`,
+	`CREATE VIEW book_titles AS SELECT title FROM book`,
+}
+
+func TestSqliteInspector(t *testing.T) {
+	db := sqlitetest.InitConnection(t, &sqlitetest.Connection{Path: filepath.Join(t.TempDir(), "test.db"), MaxConnections: 1}, godb.Options{})
+	for _, query := range schema {
+		if _, err := db.Exec(query); err != nil {
+			t.Fatal(err)
+		}
+	}
+	inspector, err := New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Run("schemas", func(t *testing.T) {
+		schemas, err := inspector.Schemas()
+		if err != nil || !reflect.DeepEqual(schemas, []string{"main"}) {
+			t.Fatal("wrong schemas", schemas, err)
+		}
+	})
+	t.Run("tables", func(t *testing.T) {
+		tables, err := inspector.Tables("")
+		expected := []Table{{Schema: "main", Name: "author"}, {Schema: "main", Name: "book"}}
+		if err != nil || !reflect.DeepEqual(tables, expected) {
+			t.Fatal("wrong tables", tables, err)
+		}
+		views, err := inspector.Views("main")
+		if err != nil || !reflect.DeepEqual(views, []Table{{Schema: "main", Name: "book_titles", View: true}}) {
+			t.Fatal("wrong views", views, err)
+		}
+	})
+	t.Run("columns", func(t *testing.T) {
+		columns, err := inspector.Columns("", "book")
+		if err != nil || len(columns) != 4 {
+			t.Fatal("wrong columns", columns, err)
+		}
+		title := columns[2]
+		if title.Name != "title" || title.Type != "TEXT" || title.Nullable || title.Position != 3 ||
+			title.Default == nil || *title.Default != "'untitled'" {
+			t.Fatal("wrong title column", title)
+		}
+		if !columns[3].Nullable || columns[3].Default != nil {
+			t.Fatal("wrong price column", columns[3])
+		}
+	})
+	t.Run("primary_key", func(t *testing.T) {
+		pk, err := inspector.PrimaryKey("", "book")
+		if err != nil || pk == nil || !reflect.DeepEqual(pk.Columns, []string{"author_id", "number"}) {
+			t.Fatal("wrong primary key", pk, err)
+		}
+		pk, err = inspector.PrimaryKey("", "book_titles")
+		if err != nil || pk != nil {
+			t.Fatal("view has no primary key", pk, err)
+		}
+	})
+	t.Run("foreign_keys", func(t *testing.T) {
+		keys, err := inspector.ForeignKeys("", "book")
+		expected := []ForeignKey{{
+			Columns:    []string{"author_id"},
+			RefSchema:  "main",
+			RefTable:   "author",
+			RefColumns: []string{"id"},
+			OnUpdate:   "NO ACTION",
+			OnDelete:   "CASCADE",
+		}}
+		if err != nil || !reflect.DeepEqual(keys, expected) {
+			t.Fatal("wrong foreign keys", keys, err)
+		}
+	})
+	t.Run("indexes", func(t *testing.T) {
+		indexes, err := inspector.Indexes("", "book")
+		expected := []Index{
+			{Name: "book_title", Columns: []string{"title", "author_id"}, Unique: true},
+			{Name: "sqlite_autoindex_book_1", Columns: []string{"author_id", "number"}, Unique: true, Primary: true},
+		}
+		if err != nil || !reflect.DeepEqual(indexes, expected) {
+			t.Fatal("wrong indexes", indexes, err)
+		}
+	})
+	t.Run("checks", func(t *testing.T) {
+		checks, err := inspector.Checks("", "book")
+		expected := []Check{
+			{Expression: "price >= 0"},
+			{Name: "title_length", Expression: "length(title) > 0 AND title <> ')'"},
+		}
+		if err != nil || !reflect.DeepEqual(checks, expected) {
+			t.Fatal("wrong checks", checks, err)
+		}
+	})
+}
+
+func TestNew(t *testing.T) {
+	var q struct{ godb.Queryer }
+	if _, err := New(q); err != ErrUnsupportedDialect {
+		t.Fatal("queryer without connection type must not be supported", err)
+	}
+}
@@ -0,0 +1,75 @@
+package introspect
+
+import (
+	"github.com/dimonrus/godb/v2"
+	"github.com/dimonrus/godb/v2/internal/dialect"
+)
+
+// mysql inspector uses information_schema
+type mysql struct {
+	q godb.Queryer
+}
+
+// Schemas list of user databases
+func (m *mysql) Schemas() ([]string, error) {
+	return names(m.q, `SELECT schema_name FROM information_schema.schemata
+WHERE schema_name NOT IN ('mysql', 'information_schema', 'performance_schema', 'sys')
+ORDER BY schema_name`)
+}
+
+// Tables list of tables in database
+func (m *mysql) Tables(schema string) ([]Table, error) {
+	return tables(m.q, false, `SELECT table_schema, table_name FROM information_schema.tables
+WHERE table_schema = `+dialect.MysqlSchema+` AND table_type = 'BASE TABLE' ORDER BY table_name`, schema)
+}
+
+// Views list of views in database
+func (m *mysql) Views(schema string) ([]Table, error) {
+	return tables(m.q, true, `SELECT table_schema, table_name FROM information_schema.views
+WHERE table_schema = `+dialect.MysqlSchema+` ORDER BY table_name`, schema)
+}
+
+// Columns list of table columns ordered by position
+func (m *mysql) Columns(schema, table string) ([]Column, error) {
+	return columns(m.q, `SELECT column_name, column_type, is_nullable = 'YES', column_default, ordinal_position
+FROM information_schema.columns
+WHERE table_schema = `+dialect.MysqlSchema+` AND table_name = ?
+ORDER BY ordinal_position`, schema, table)
+}
+
+// PrimaryKey primary key of table
+func (m *mysql) PrimaryKey(schema, table string) (*PrimaryKey, error) {
+	return primaryKey(m.q, `SELECT constraint_name, column_name
+FROM information_schema.key_column_usage
+WHERE table_schema = `+dialect.MysqlSchema+` AND table_name = ? AND constraint_name = 'PRIMARY'
+ORDER BY ordinal_position`, schema, table)
+}
+
+// ForeignKeys list of table foreign keys
+func (m *mysql) ForeignKeys(schema, table string) ([]ForeignKey, error) {
+	return foreignKeys(m.q, nil, `SELECT k.constraint_name, k.constraint_name, k.column_name,
+k.referenced_table_schema, k.referenced_table_name, k.referenced_column_name, r.update_rule, r.delete_rule
+FROM information_schema.key_column_usage k
+JOIN information_schema.referential_constraints r
+ON r.constraint_schema = k.constraint_schema AND r.constraint_name = k.constraint_name AND r.table_name = k.table_name
+WHERE k.table_schema = `+dialect.MysqlSchema+` AND k.table_name = ? AND k.referenced_table_name IS NOT NULL
+ORDER BY k.constraint_name, k.ordinal_position`, schema, table)
+}
+
+// Indexes list of table indexes. Functional key parts have empty column
+func (m *mysql) Indexes(schema, table string) ([]Index, error) {
+	return indexes(m.q, `SELECT index_name, non_unique = 0, index_name = 'PRIMARY', COALESCE(column_name, '')
+FROM information_schema.statistics
+WHERE table_schema = `+dialect.MysqlSchema+` AND table_name = ?
+ORDER BY index_name, seq_in_index`, schema, table)
+}
+
+// Checks list of table check constraints. Requires MySQL 8.0.16
+func (m *mysql) Checks(schema, table string) ([]Check, error) {
+	return checks(m.q, `SELECT tc.constraint_name, cc.check_clause
+FROM information_schema.table_constraints tc
+JOIN information_schema.check_constraints cc
+ON cc.constraint_schema = tc.constraint_schema AND cc.constraint_name = tc.constraint_name
+WHERE tc.table_schema = `+dialect.MysqlSchema+` AND tc.table_name = ? AND tc.constraint_type = 'CHECK'
+ORDER BY tc.constraint_name`, schema, table)
+}
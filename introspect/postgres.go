@@ -0,0 +1,105 @@
+package introspect
+
+import "github.com/dimonrus/godb/v2"
+
+// postgres inspector uses pg_catalog for constraints and indexes
+type postgres struct {
+	q godb.Queryer
+}
+
+// schema filter, current schema if empty
+const pgSchema = "COALESCE(NULLIF($1, ''), current_schema())"
+
+// Schemas list of user schemas
+func (p *postgres) Schemas() ([]string, error) {
+	return names(p.q, `SELECT nspname FROM pg_catalog.pg_namespace
+WHERE nspname NOT IN ('pg_catalog', 'information_schema') AND nspname NOT LIKE 'pg_toast%' AND nspname NOT LIKE 'pg_temp%'
+ORDER BY nspname`)
+}
+
+// Tables list of tables in schema
+func (p *postgres) Tables(schema string) ([]Table, error) {
+	return tables(p.q, false, `SELECT table_schema, table_name FROM information_schema.tables
+WHERE table_schema = `+pgSchema+` AND table_type = 'BASE TABLE' ORDER BY table_name`, schema)
+}
+
+// Views list of views in schema
+func (p *postgres) Views(schema string) ([]Table, error) {
+	return tables(p.q, true, `SELECT table_schema, table_name FROM information_schema.views
+WHERE table_schema = `+pgSchema+` ORDER BY table_name`, schema)
+}
+
+// Columns list of table columns ordered by position
+func (p *postgres) Columns(schema, table string) ([]Column, error) {
+	return columns(p.q, `SELECT a.attname, format_type(a.atttypid, a.atttypmod), NOT a.attnotnull, pg_get_expr(d.adbin, d.adrelid), a.attnum
+FROM pg_catalog.pg_attribute a
+JOIN pg_catalog.pg_class c ON c.oid = a.attrelid
+JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+LEFT JOIN pg_catalog.pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+WHERE n.nspname = `+pgSchema+` AND c.relname = $2 AND a.attnum > 0 AND NOT a.attisdropped
+ORDER BY a.attnum`, schema, table)
+}
+
+// PrimaryKey primary key of table
+func (p *postgres) PrimaryKey(schema, table string) (*PrimaryKey, error) {
+	return primaryKey(p.q, `SELECT con.conname, a.attname
+FROM pg_catalog.pg_constraint con
+JOIN pg_catalog.pg_class c ON c.oid = con.conrelid
+JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+JOIN LATERAL unnest(con.conkey) WITH ORDINALITY AS k(attnum, ord) ON true
+JOIN pg_catalog.pg_attribute a ON a.attrelid = c.oid AND a.attnum = k.attnum
+WHERE con.contype = 'p' AND n.nspname = `+pgSchema+` AND c.relname = $2
+ORDER BY k.ord`, schema, table)
+}
+
+// ForeignKeys list of table foreign keys
+func (p *postgres) ForeignKeys(schema, table string) ([]ForeignKey, error) {
+	return foreignKeys(p.q, pgAction, `SELECT con.conname, con.conname, a.attname, rn.nspname, rc.relname, ra.attname, con.confupdtype, con.confdeltype
+FROM pg_catalog.pg_constraint con
+JOIN pg_catalog.pg_class c ON c.oid = con.conrelid
+JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+JOIN pg_catalog.pg_class rc ON rc.oid = con.confrelid
+JOIN pg_catalog.pg_namespace rn ON rn.oid = rc.relnamespace
+JOIN LATERAL unnest(con.conkey, con.confkey) WITH ORDINALITY AS k(attnum, refnum, ord) ON true
+JOIN pg_catalog.pg_attribute a ON a.attrelid = c.oid AND a.attnum = k.attnum
+JOIN pg_catalog.pg_attribute ra ON ra.attrelid = rc.oid AND ra.attnum = k.refnum
+WHERE con.contype = 'f' AND n.nspname = `+pgSchema+` AND c.relname = $2
+ORDER BY con.conname, k.ord`, schema, table)
+}
+
+// Indexes list of table indexes. Expressions are returned instead of columns for expression indexes
+func (p *postgres) Indexes(schema, table string) ([]Index, error) {
+	return indexes(p.q, `SELECT ic.relname, ix.indisunique, ix.indisprimary, pg_get_indexdef(ix.indexrelid, k.ord::int, true)
+FROM pg_catalog.pg_index ix
+JOIN pg_catalog.pg_class c ON c.oid = ix.indrelid
+JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+JOIN pg_catalog.pg_class ic ON ic.oid = ix.indexrelid
+JOIN LATERAL unnest(ix.indkey::int2[]) WITH ORDINALITY AS k(attnum, ord) ON true
+WHERE n.nspname = `+pgSchema+` AND c.relname = $2 AND k.ord <= ix.indnkeyatts
+ORDER BY ic.relname, k.ord`, schema, table)
+}
+
+// Checks list of table check constraints
+func (p *postgres) Checks(schema, table string) ([]Check, error) {
+	return checks(p.q, `SELECT con.conname, pg_get_constraintdef(con.oid, true)
+FROM pg_catalog.pg_constraint con
+JOIN pg_catalog.pg_class c ON c.oid = con.conrelid
+JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+WHERE con.contype = 'c' AND n.nspname = `+pgSchema+` AND c.relname = $2
+ORDER BY con.conname`, schema, table)
+}
+
+// pgAction foreign key action by pg_constraint code
+func pgAction(code string) string {
+	switch code {
+	case "r":
+		return "RESTRICT"
+	case "c":
+		return "CASCADE"
+	case "n":
+		return "SET NULL"
+	case "d":
+		return "SET DEFAULT"
+	}
+	return "NO ACTION"
+}
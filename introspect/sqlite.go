@@ -0,0 +1,205 @@
+package introspect
+
+import (
+	"database/sql"
+	"github.com/dimonrus/godb/v2"
+	"github.com/dimonrus/godb/v2/internal/dialect"
+	"strings"
+)
+
+// sqlite inspector uses pragma functions. Schema is attached database name
+type sqlite struct {
+	q godb.Queryer
+}
+
+// Schemas list of attached databases
+func (s *sqlite) Schemas() ([]string, error) {
+	return names(s.q, "SELECT name FROM pragma_database_list ORDER BY seq")
+}
+
+// Tables list of tables in schema
+func (s *sqlite) Tables(schema string) ([]Table, error) {
+	schema = dialect.SqliteSchema(schema)
+	return tables(s.q, false, `SELECT ?, name FROM `+dialect.QuoteIdent(schema)+`.sqlite_master
+WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name`, schema)
+}
+
+// Views list of views in schema
+func (s *sqlite) Views(schema string) ([]Table, error) {
+	schema = dialect.SqliteSchema(schema)
+	return tables(s.q, true, `SELECT ?, name FROM `+dialect.QuoteIdent(schema)+`.sqlite_master
+WHERE type = 'view' ORDER BY name`, schema)
+}
+
+// Columns list of table columns ordered by position
+func (s *sqlite) Columns(schema, table string) ([]Column, error) {
+	return columns(s.q, `SELECT name, type, "notnull" = 0, dflt_value, cid + 1
+FROM pragma_table_info(?, ?) ORDER BY cid`, table, dialect.SqliteSchema(schema))
+}
+
+// PrimaryKey primary key of table. SQLite has no primary key names
+func (s *sqlite) PrimaryKey(schema, table string) (*PrimaryKey, error) {
+	return primaryKey(s.q, `SELECT '', name FROM pragma_table_info(?, ?) WHERE pk > 0 ORDER BY pk`,
+		table, dialect.SqliteSchema(schema))
+}
+
+// ForeignKeys list of table foreign keys. SQLite has no foreign key names
+// Primary key of referenced table is used if referenced columns are omitted
+func (s *sqlite) ForeignKeys(schema, table string) ([]ForeignKey, error) {
+	schema = dialect.SqliteSchema(schema)
+	result, err := foreignKeys(s.q, nil, `SELECT id, '', "from", ?, "table", COALESCE("to", ''), on_update, on_delete
+FROM pragma_foreign_key_list(?, ?) ORDER BY id, seq`, schema, table, schema)
+	if err != nil {
+		return nil, err
+	}
+	for i, fk := range result {
+		if fk.RefColumns[0] != "" {
+			continue
+		}
+		pk, err := s.PrimaryKey(fk.RefSchema, fk.RefTable)
+		if err != nil {
+			return nil, err
+		}
+		if pk != nil {
+			result[i].RefColumns = pk.Columns
+		}
+	}
+	return result, nil
+}
+
+// Indexes list of table indexes. Expression key parts have empty column
+// Integer primary key is not listed because it is rowid alias
+func (s *sqlite) Indexes(schema, table string) ([]Index, error) {
+	schema = dialect.SqliteSchema(schema)
+	return indexes(s.q, `SELECT il.name, il."unique", il.origin = 'pk', COALESCE(ii.name, '')
+FROM pragma_index_list(?, ?) il
+JOIN pragma_index_info(il.name, ?) ii
+ORDER BY il.name, ii.seqno`, table, schema, schema)
+}
+
+// Checks list of table check constraints parsed from table definition
+func (s *sqlite) Checks(schema, table string) ([]Check, error) {
+	var ddl sql.NullString
+	err := s.q.QueryRow(`SELECT sql FROM `+dialect.QuoteIdent(dialect.SqliteSchema(schema))+`.sqlite_master
+WHERE type = 'table' AND name = ?`, table).Scan(&ddl)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sqliteChecks(ddl.String), nil
+}
+
+// token of table definition
+type token struct {
+	text       string
+	start, end int
+}
+
+// tokenize table definition. Comments are skipped, quoted literals and identifiers are single tokens
+func tokenize(ddl string) []token {
+	var result []token
+	for i := 0; i < len(ddl); {
+		c := ddl[i]
+		start := i
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+		case c == '-' && i+1 < len(ddl) && ddl[i+1] == '-':
+			for i < len(ddl) && ddl[i] != '\n' {
+				i++
+			}
+			continue
+		case c == '/' && i+1 < len(ddl) && ddl[i+1] == '*':
+			end := strings.Index(ddl[i+2:], "*/")
+			if end < 0 {
+				return result
+			}
+			i += end + 4
+			continue
+		case c == '\'' || c == '"' || c == '`' || c == '[':
+			closing := c
+			if c == '[' {
+				closing = ']'
+			}
+			for i++; i < len(ddl); i++ {
+				if ddl[i] == closing {
+					// doubled quote is escaped quote
+					if closing != ']' && i+1 < len(ddl) && ddl[i+1] == closing {
+						i++
+						continue
+					}
+					break
+				}
+			}
+			i++
+		case c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			for i < len(ddl) && (ddl[i] == '_' || ddl[i] == '$' || ddl[i] >= '0' && ddl[i] <= '9' ||
+				ddl[i] >= 'a' && ddl[i] <= 'z' || ddl[i] >= 'A' && ddl[i] <= 'Z') {
+				i++
+			}
+		default:
+			i++
+		}
+		if i > len(ddl) {
+			i = len(ddl)
+		}
+		result = append(result, token{text: ddl[start:i], start: start, end: i})
+	}
+	return result
+}
+
+// unquote identifier
+func unquote(name string) string {
+	if len(name) < 2 {
+		return name
+	}
+	switch name[0] {
+	case '"', '`':
+		q := name[:1]
+		return strings.ReplaceAll(name[1:len(name)-1], q+q, q)
+	case '[':
+		return name[1 : len(name)-1]
+	}
+	return name
+}
+
+// sqliteChecks parse check constraints of table definition
+func sqliteChecks(ddl string) []Check {
+	var result []Check
+	var name string
+	tokens := tokenize(ddl)
+	for i := 0; i < len(tokens); i++ {
+		switch strings.ToUpper(tokens[i].text) {
+		case "CONSTRAINT":
+			if i+1 < len(tokens) {
+				i++
+				name = unquote(tokens[i].text)
+			}
+			continue
+		case "CHECK":
+			if i+1 >= len(tokens) || tokens[i+1].text != "(" {
+				break
+			}
+			depth := 0
+			for j := i + 1; j < len(tokens); j++ {
+				switch tokens[j].text {
+				case "(":
+					depth++
+				case ")":
+					depth--
+				}
+				if depth == 0 {
+					expression := strings.TrimSpace(ddl[tokens[i+1].end:tokens[j].start])
+					result = append(result, Check{Name: name, Expression: expression})
+					i = j
+					break
+				}
+			}
+		}
+		name = ""
+	}
+	return result
+}
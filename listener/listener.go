@@ -0,0 +1,177 @@
+package listener
+
+import (
+	"github.com/dimonrus/gocli"
+	"github.com/dimonrus/godb/v2"
+	"github.com/lib/pq"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMinReconnectInterval delay before first reconnect attempt
+	DefaultMinReconnectInterval = time.Second
+	// DefaultMaxReconnectInterval maximum delay between reconnect attempts
+	DefaultMaxReconnectInterval = time.Minute
+	// DefaultPingInterval connection check interval
+	DefaultPingInterval = time.Second * 90
+	// DefaultBufferSize notifications buffer size
+	DefaultBufferSize = 64
+)
+
+// Notification postgres notification
+type Notification struct {
+	// Channel name
+	Channel string
+	// Notification payload
+	Payload string
+	// Process id of notifying backend
+	PID int
+}
+
+// Config listener options
+type Config struct {
+	// Delay before first reconnect attempt. Doubled after each failed attempt. Default DefaultMinReconnectInterval
+	MinReconnectInterval time.Duration
+	// Maximum delay between reconnect attempts. Default DefaultMaxReconnectInterval
+	MaxReconnectInterval time.Duration
+	// Connection check interval. Default DefaultPingInterval
+	PingInterval time.Duration
+	// Notifications buffer size. Default DefaultBufferSize
+	BufferSize int
+	// Logger of connection events
+	Logger gocli.Logger
+	// Called after reconnect. Notifications sent while connection was lost are not delivered
+	OnReconnect func()
+}
+
+// pq listener methods used by Listener
+type pqListener interface {
+	Listen(channel string) error
+	Unlisten(channel string) error
+	Ping() error
+	Close() error
+	NotificationChannel() <-chan *pq.Notification
+}
+
+// Listener postgres LISTEN connection
+// Connection is re-established and channels are subscribed again automatically after connection loss
+type Listener struct {
+	config        Config
+	listener      pqListener
+	notifications chan Notification
+	done          chan struct{}
+	once          sync.Once
+}
+
+// New listener for postgres connection config
+func New(connection *godb.PostgresConnectionConfig, config Config) *Listener {
+	if config.MinReconnectInterval <= 0 {
+		config.MinReconnectInterval = DefaultMinReconnectInterval
+	}
+	if config.MaxReconnectInterval <= 0 {
+		config.MaxReconnectInterval = DefaultMaxReconnectInterval
+	}
+	l := &Listener{config: config}
+	return l.start(pq.NewListener(connection.String(), config.MinReconnectInterval, config.MaxReconnectInterval, l.event))
+}
+
+// start delivering notifications of pq listener
+func (l *Listener) start(listener pqListener) *Listener {
+	if l.config.PingInterval <= 0 {
+		l.config.PingInterval = DefaultPingInterval
+	}
+	if l.config.BufferSize <= 0 {
+		l.config.BufferSize = DefaultBufferSize
+	}
+	l.listener = listener
+	l.notifications = make(chan Notification, l.config.BufferSize)
+	l.done = make(chan struct{})
+	go l.run()
+	return l
+}
+
+// Listen subscribe to channels. Channel names are case-sensitive
+func (l *Listener) Listen(channels ...string) error {
+	for _, channel := range channels {
+		err := l.listener.Listen(channel)
+		if err != nil && err != pq.ErrChannelAlreadyOpen {
+			return err
+		}
+	}
+	return nil
+}
+
+// Unlisten unsubscribe from channels
+func (l *Listener) Unlisten(channels ...string) error {
+	for _, channel := range channels {
+		err := l.listener.Unlisten(channel)
+		if err != nil && err != pq.ErrChannelNotOpen {
+			return err
+		}
+	}
+	return nil
+}
+
+// Notifications channel of received notifications. Closed after listener is closed
+func (l *Listener) Notifications() <-chan Notification {
+	return l.notifications
+}
+
+// Close connection. Undelivered notifications are dropped
+func (l *Listener) Close() error {
+	var err error
+	l.once.Do(func() {
+		close(l.done)
+		err = l.listener.Close()
+	})
+	return err
+}
+
+// run deliver notifications until pq listener is closed
+func (l *Listener) run() {
+	defer close(l.notifications)
+	ticker := time.NewTicker(l.config.PingInterval)
+	defer ticker.Stop()
+	incoming := l.listener.NotificationChannel()
+	for {
+		select {
+		case n, ok := <-incoming:
+			if !ok {
+				return
+			}
+			if n == nil {
+				// connection is re-established
+				if l.config.OnReconnect != nil {
+					l.config.OnReconnect()
+				}
+				continue
+			}
+			select {
+			case l.notifications <- Notification{Channel: n.Channel, Payload: n.Extra, PID: n.BePid}:
+			case <-l.done:
+			}
+		case <-ticker.C:
+			go func() {
+				if err := l.listener.Ping(); err != nil && l.config.Logger != nil {
+					l.config.Logger.Warnf("listener ping failed: %s", err)
+				}
+			}()
+		}
+	}
+}
+
+// event log connection events
+func (l *Listener) event(event pq.ListenerEventType, err error) {
+	if l.config.Logger == nil {
+		return
+	}
+	switch event {
+	case pq.ListenerEventDisconnected:
+		l.config.Logger.Warnf("listener disconnected: %s", err)
+	case pq.ListenerEventConnectionAttemptFailed:
+		l.config.Logger.Warnf("listener connection attempt failed: %s", err)
+	case pq.ListenerEventReconnected:
+		l.config.Logger.Infoln("listener reconnected")
+	}
+}
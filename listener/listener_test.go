@@ -0,0 +1,138 @@
+package listener
+
+import (
+	"github.com/dimonrus/godb/v2"
+	"github.com/lib/pq"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fake pq listener
+type fakeListener struct {
+	m        sync.Mutex
+	channels map[string]bool
+	pings    int
+	closed   bool
+	notify   chan *pq.Notification
+}
+
+func newFakeListener() *fakeListener {
+	return &fakeListener{channels: make(map[string]bool), notify: make(chan *pq.Notification)}
+}
+
+func (f *fakeListener) Listen(channel string) error {
+	f.m.Lock()
+	defer f.m.Unlock()
+	if f.channels[channel] {
+		return pq.ErrChannelAlreadyOpen
+	}
+	f.channels[channel] = true
+	return nil
+}
+
+func (f *fakeListener) Unlisten(channel string) error {
+	f.m.Lock()
+	defer f.m.Unlock()
+	if !f.channels[channel] {
+		return pq.ErrChannelNotOpen
+	}
+	delete(f.channels, channel)
+	return nil
+}
+
+func (f *fakeListener) Ping() error {
+	f.m.Lock()
+	f.pings++
+	f.m.Unlock()
+	return nil
+}
+
+func (f *fakeListener) Close() error {
+	f.m.Lock()
+	defer f.m.Unlock()
+	f.closed = true
+	return nil
+}
+
+func (f *fakeListener) NotificationChannel() <-chan *pq.Notification {
+	return f.notify
+}
+
+func TestListener(t *testing.T) {
+	fake := newFakeListener()
+	reconnected := make(chan struct{}, 1)
+	l := (&Listener{config: Config{
+		PingInterval: time.Millisecond * 10,
+		BufferSize:   1,
+		OnReconnect: func() {
+			reconnected <- struct{}{}
+		},
+	}}).start(fake)
+	t.Run("listen", func(t *testing.T) {
+		if err := l.Listen("cache", "cache", "jobs"); err != nil {
+			t.Fatal("repeated listen must not fail", err)
+		}
+		if err := l.Unlisten("jobs", "unknown"); err != nil {
+			t.Fatal("unlisten of unknown channel must not fail", err)
+		}
+		if !fake.channels["cache"] || fake.channels["jobs"] {
+			t.Fatal("wrong channels", fake.channels)
+		}
+	})
+	t.Run("notifications", func(t *testing.T) {
+		fake.notify <- &pq.Notification{Channel: "cache", Extra: "users:1", BePid: 42}
+		n := <-l.Notifications()
+		if n.Channel != "cache" || n.Payload != "users:1" || n.PID != 42 {
+			t.Fatal("wrong notification", n)
+		}
+		fake.notify <- nil
+		select {
+		case <-reconnected:
+		case <-time.After(time.Second):
+			t.Fatal("reconnect must be reported")
+		}
+	})
+	t.Run("ping", func(t *testing.T) {
+		time.Sleep(time.Millisecond * 50)
+		fake.m.Lock()
+		pings := fake.pings
+		fake.m.Unlock()
+		if pings == 0 {
+			t.Fatal("connection must be checked")
+		}
+	})
+	t.Run("close", func(t *testing.T) {
+		// buffer is full, undelivered notifications must not block pq listener after close
+		fake.notify <- &pq.Notification{Channel: "cache"}
+		fake.notify <- &pq.Notification{Channel: "cache"}
+		if err := l.Close(); err != nil || !fake.closed {
+			t.Fatal("pq listener must be closed", err)
+		}
+		fake.notify <- &pq.Notification{Channel: "cache"}
+		close(fake.notify)
+		count := 0
+		for range l.Notifications() {
+			count++
+		}
+		if count > 1 {
+			t.Fatal("only buffered notification must be delivered", count)
+		}
+	})
+}
+
+func TestNew(t *testing.T) {
+	l := New(&godb.PostgresConnectionConfig{ConnectionConfig: godb.ConnectionConfig{Host: "127.0.0.1", Port: 1, Name: "test", User: "test"}},
+		Config{MinReconnectInterval: time.Millisecond, MaxReconnectInterval: time.Millisecond})
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case _, ok := <-l.Notifications():
+		if ok {
+			t.Fatal("notifications must be closed")
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("listener must stop after close")
+	}
+}
@@ -0,0 +1,107 @@
+package godb
+
+import (
+	"gopkg.in/yaml.v3"
+	"os"
+	"strings"
+)
+
+// EnvConfig config that can be loaded from file and environment
+type EnvConfig interface {
+	// ApplyEnv overlay config by environment variables and secret files
+	ApplyEnv(prefix string) error
+	// Validate check config fields
+	Validate() error
+}
+
+// PoolConfig connection with pool settings checked by Init
+type PoolConfig interface {
+	// ValidatePool check pool settings
+	ValidatePool() error
+}
+
+// ConfigDefaults config which can fill empty fields by defaults
+type ConfigDefaults interface {
+	// SetDefaults fill empty fields by defaults
+	SetDefaults()
+}
+
+// FieldError invalid config field
+type FieldError struct {
+	// Field name
+	Field string
+	// Reason of error
+	Reason string
+}
+
+// ConfigError list of invalid config fields
+type ConfigError struct {
+	// Invalid fields
+	Fields []FieldError
+}
+
+// Add invalid field
+func (e *ConfigError) Add(field, reason string) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Reason: reason})
+}
+
+// Merge fields from error if it is config error
+func (e *ConfigError) Merge(err error) {
+	if ce, ok := err.(*ConfigError); ok && ce != nil {
+		e.Fields = append(e.Fields, ce.Fields...)
+	}
+}
+
+// Err return nil if there are no invalid fields
+func (e *ConfigError) Err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Error message
+func (e *ConfigError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		messages[i] = f.Field + " " + f.Reason
+	}
+	return "invalid config: " + strings.Join(messages, "; ")
+}
+
+// LoadConfig fill config from yaml file, overlay it by environment variables, set defaults and validate
+// Empty path skips reading file, empty prefix means DefaultEnvPrefix
+func LoadConfig(path string, prefix string, config EnvConfig) error {
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		err = yaml.Unmarshal(data, config)
+		if err != nil {
+			return err
+		}
+	}
+	if prefix == "" {
+		prefix = DefaultEnvPrefix
+	}
+	e := &ConfigError{}
+	err := config.ApplyEnv(prefix)
+	if err != nil {
+		if _, ok := err.(*ConfigError); !ok {
+			return err
+		}
+		e.Merge(err)
+	}
+	if d, ok := config.(ConfigDefaults); ok {
+		d.SetDefaults()
+	}
+	err = config.Validate()
+	if err != nil {
+		if _, ok := err.(*ConfigError); !ok {
+			return err
+		}
+		e.Merge(err)
+	}
+	return e.Err()
+}
@@ -0,0 +1,170 @@
+package godb
+
+import (
+	"github.com/dimonrus/gocli"
+	"sync"
+	"sync/atomic"
+)
+
+// LogPolicy behaviour when query log buffer is full
+type LogPolicy string
+
+const (
+	// LogDropNewest drop message which does not fit the buffer
+	LogDropNewest LogPolicy = "dropNewest"
+	// LogDropOldest drop the oldest buffered message in favour of new one
+	LogDropOldest LogPolicy = "dropOldest"
+	// LogBlock wait until buffer has free space
+	LogBlock LogPolicy = "block"
+)
+
+// DefaultLogBatchSize maximum messages written to logger at once if batch size is not set
+const DefaultLogBatchSize = 64
+
+// BatchLogger logger which can write several messages at once
+type BatchLogger interface {
+	// PrintBatch write messages
+	PrintBatch(messages []string)
+}
+
+// queryLogger writes query log messages to logger
+type queryLogger struct {
+	// dropped messages counter. First field for atomic alignment
+	dropped  uint64
+	m        sync.RWMutex
+	closed   bool
+	logger   gocli.Logger
+	policy   LogPolicy
+	batch    int
+	sync     bool
+	messages chan string
+	done     chan struct{}
+}
+
+// Create query logger and start background writer if logger is asynchronous
+func newQueryLogger(options Options, bufferSize int) *queryLogger {
+	if options.LogBufferSize > 0 {
+		bufferSize = options.LogBufferSize
+	}
+	l := &queryLogger{
+		logger: options.Logger,
+		policy: options.LogPolicy,
+		batch:  options.LogBatchSize,
+		sync:   options.LogSync,
+		done:   make(chan struct{}),
+	}
+	if l.policy == "" {
+		l.policy = LogDropNewest
+	}
+	if l.batch <= 0 {
+		l.batch = DefaultLogBatchSize
+	}
+	if l.sync {
+		close(l.done)
+		return l
+	}
+	l.messages = make(chan string, bufferSize)
+	go l.run()
+	return l
+}
+
+// run write buffered messages by batches until channel is closed
+func (l *queryLogger) run() {
+	defer close(l.done)
+	batch := make([]string, 0, l.batch)
+	for message := range l.messages {
+		batch = append(batch[:0], message)
+	read:
+		for len(batch) < l.batch {
+			select {
+			case message, ok := <-l.messages:
+				if !ok {
+					break read
+				}
+				batch = append(batch, message)
+			default:
+				break read
+			}
+		}
+		l.print(batch)
+	}
+}
+
+// print messages to logger
+func (l *queryLogger) print(messages []string) {
+	if bl, ok := l.logger.(BatchLogger); ok {
+		bl.PrintBatch(messages)
+		return
+	}
+	for _, message := range messages {
+		l.logger.Println(message)
+	}
+}
+
+// write message according to policy. Messages are dropped after close
+func (l *queryLogger) write(message string) {
+	if l.sync {
+		l.m.Lock()
+		defer l.m.Unlock()
+		if !l.closed {
+			l.print([]string{message})
+		}
+		return
+	}
+	l.m.RLock()
+	defer l.m.RUnlock()
+	if l.closed {
+		return
+	}
+	switch l.policy {
+	case LogBlock:
+		l.messages <- message
+	case LogDropOldest:
+		for {
+			select {
+			case l.messages <- message:
+				return
+			default:
+			}
+			select {
+			case <-l.messages:
+				atomic.AddUint64(&l.dropped, 1)
+			default:
+			}
+		}
+	default:
+		select {
+		case l.messages <- message:
+		default:
+			atomic.AddUint64(&l.dropped, 1)
+		}
+	}
+}
+
+// droppedCount count of dropped messages
+func (l *queryLogger) droppedCount() uint64 {
+	return atomic.LoadUint64(&l.dropped)
+}
+
+// close logger and wait until all buffered messages are written
+func (l *queryLogger) close() {
+	l.m.Lock()
+	if l.closed {
+		l.m.Unlock()
+		return
+	}
+	l.closed = true
+	if l.messages != nil {
+		close(l.messages)
+	}
+	l.m.Unlock()
+	<-l.done
+}
+
+// DroppedLogMessages count of query log messages dropped because of full buffer
+func (dbo *DBO) DroppedLogMessages() uint64 {
+	if dbo.queryLog == nil {
+		return 0
+	}
+	return dbo.queryLog.droppedCount()
+}
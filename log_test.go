@@ -0,0 +1,95 @@
+package godb
+
+import (
+	"testing"
+	"time"
+)
+
+// logger blocks until released
+type slowLogger struct {
+	*testLogger
+	release chan struct{}
+}
+
+func (l *slowLogger) Println(v ...interface{}) {
+	<-l.release
+	l.testLogger.Println(v...)
+}
+
+// logger receives batches
+type batchLogger struct {
+	*testLogger
+	batches [][]string
+}
+
+func (l *batchLogger) PrintBatch(messages []string) {
+	l.m.Lock()
+	l.batches = append(l.batches, append([]string(nil), messages...))
+	l.messages = append(l.messages, messages...)
+	l.m.Unlock()
+}
+
+func TestQueryLogger(t *testing.T) {
+	t.Run("drop_newest", func(t *testing.T) {
+		lg := &slowLogger{testLogger: newTestLogger(), release: make(chan struct{})}
+		l := newQueryLogger(Options{Logger: lg, LogBatchSize: 1}, 2)
+		done := make(chan struct{})
+		go func() {
+			for i := 0; i < 10; i++ {
+				l.write("message")
+			}
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("write must not block")
+		}
+		close(lg.release)
+		l.close()
+		if l.droppedCount()+uint64(lg.count()) != 10 || l.droppedCount() < 7 {
+			t.Fatal("wrong dropped count", l.droppedCount(), lg.count())
+		}
+	})
+	t.Run("drop_oldest", func(t *testing.T) {
+		lg := newTestLogger()
+		l := &queryLogger{logger: lg, policy: LogDropOldest, batch: 1, messages: make(chan string, 2), done: make(chan struct{})}
+		l.write("1")
+		l.write("2")
+		l.write("3")
+		if l.droppedCount() != 1 {
+			t.Fatal("wrong dropped count", l.droppedCount())
+		}
+		go l.run()
+		l.close()
+		if lg.count() != 2 || lg.messages[0] != "2" || lg.messages[1] != "3" {
+			t.Fatal("oldest message must be dropped", lg.messages)
+		}
+	})
+	t.Run("batch", func(t *testing.T) {
+		lg := &batchLogger{testLogger: newTestLogger()}
+		l := &queryLogger{logger: lg, policy: LogBlock, batch: 3, messages: make(chan string, 10), done: make(chan struct{})}
+		for i := 0; i < 7; i++ {
+			l.write("message")
+		}
+		go l.run()
+		l.close()
+		if len(lg.batches) != 3 || len(lg.batches[0]) != 3 || len(lg.batches[2]) != 1 {
+			t.Fatal("wrong batches", lg.batches)
+		}
+	})
+	t.Run("sync", func(t *testing.T) {
+		lg := newTestLogger()
+		db := initSqlite(t, Options{Debug: true, Logger: lg, LogSync: true})
+		_, err := db.Exec("SELECT 1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lg.count() != 1 {
+			t.Fatal("message must be written synchronously")
+		}
+		if db.DroppedLogMessages() != 0 {
+			t.Fatal("wrong dropped count")
+		}
+	})
+}
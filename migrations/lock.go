@@ -0,0 +1,257 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/dimonrus/godb/v2"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultLockTimeout maximum time to wait for migration lock
+	DefaultLockTimeout = time.Minute
+	// LockTable table of sqlite locks
+	LockTable = "schema_locks"
+	// interval between lock attempts
+	lockPollInterval = time.Millisecond * 500
+)
+
+// lockLease expiry of sqlite lock row. Holder extends it every third of lease
+var lockLease = time.Second * 30
+
+// ErrLockTimeout lock is held by other process longer than timeout
+var ErrLockTimeout = errors.New("migrations: lock timeout")
+
+// Lock cluster-wide named lock
+// Postgres advisory lock and mysql named lock are bound to connection, so connection is reserved until release
+// Sqlite lock is a row of LockTable inserted in exclusive transaction. Busy database is retried until timeout
+// Row expires after lease independent of wait timeout, holder extends expiry while lock is held,
+// so expired row is left by crashed process and is taken over
+// Postgres lock key is godb.AdvisoryKey of name, so application may share lock with godb.DBO.AdvisoryLock
+type Lock struct {
+	dbo     *godb.DBO
+	conn    *sql.Conn
+	dialect string
+	name    string
+	owner   string
+	// sqlite row expiry
+	lease time.Duration
+	// stop sqlite heartbeat
+	stop chan struct{}
+	done chan struct{}
+}
+
+// AcquireLock wait for named lock until timeout. DefaultLockTimeout is used if timeout is not set
+// Holder of lock is logged while waiting
+func AcquireLock(ctx context.Context, dbo *godb.DBO, name string, timeout time.Duration) (*Lock, error) {
+	if timeout <= 0 {
+		timeout = DefaultLockTimeout
+	}
+	lockCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	conn, err := dbo.DB.Conn(lockCtx)
+	if err != nil {
+		return nil, err
+	}
+	hostname, _ := os.Hostname()
+	l := &Lock{
+		dbo:     dbo,
+		conn:    conn,
+		dialect: dbo.ConnType(),
+		name:    name,
+		owner:   fmt.Sprintf("%s:%d", hostname, os.Getpid()),
+		lease:   lockLease,
+	}
+	var logged string
+	for {
+		ok, err := l.try(lockCtx)
+		if err == nil && ok {
+			l.log(false, "lock %s acquired by %s", name, l.owner)
+			if l.dialect == "sqlite3" {
+				l.stop, l.done = make(chan struct{}), make(chan struct{})
+				go l.heartbeat()
+			}
+			return l, nil
+		}
+		if err != nil && !isBusy(err) && lockCtx.Err() == nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		holder := l.holder(lockCtx)
+		if holder != logged && lockCtx.Err() == nil {
+			l.log(true, "lock %s is held by %s, waiting", name, holder)
+			logged = holder
+		}
+		select {
+		case <-lockCtx.Done():
+			_ = conn.Close()
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, fmt.Errorf("%w: %s is held by %s", ErrLockTimeout, name, logged)
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+// Release lock and connection
+func (l *Lock) Release() error {
+	ctx := context.Background()
+	if l.stop != nil {
+		close(l.stop)
+		<-l.done
+	}
+	var err error
+	switch l.dialect {
+	case "postgres":
+		_, err = l.conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", godb.AdvisoryKey(l.name))
+	case "mysql":
+		_, err = l.conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", l.name)
+	case "sqlite3":
+		_, err = l.conn.ExecContext(ctx, "DELETE FROM "+LockTable+" WHERE name = ? AND owner = ?", l.name, l.owner)
+	}
+	if e := l.conn.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		l.log(false, "lock %s released by %s", l.name, l.owner)
+	}
+	return err
+}
+
+// try acquire lock once
+func (l *Lock) try(ctx context.Context) (bool, error) {
+	var ok bool
+	switch l.dialect {
+	case "postgres":
+		err := l.conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", godb.AdvisoryKey(l.name)).Scan(&ok)
+		return ok, err
+	case "mysql":
+		err := l.conn.QueryRowContext(ctx, "SELECT COALESCE(GET_LOCK(?, 0), 0) = 1", l.name).Scan(&ok)
+		return ok, err
+	case "sqlite3":
+		return l.trySqlite(ctx)
+	}
+	return false, fmt.Errorf("migrations: lock is not supported for %s", l.dialect)
+}
+
+// trySqlite insert lock row in exclusive transaction if there is no row or row is expired
+func (l *Lock) trySqlite(ctx context.Context) (ok bool, err error) {
+	_, err = l.conn.ExecContext(ctx, "BEGIN EXCLUSIVE")
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err != nil {
+			_, _ = l.conn.ExecContext(context.Background(), "ROLLBACK")
+		}
+	}()
+	_, err = l.conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+LockTable+` (
+	name TEXT NOT NULL PRIMARY KEY,
+	owner TEXT NOT NULL,
+	locked_at TIMESTAMP NOT NULL,
+	expires_at TIMESTAMP NOT NULL
+)`)
+	if err != nil {
+		return false, err
+	}
+	var count int
+	var owner string
+	var expiresAt time.Time
+	err = l.conn.QueryRowContext(ctx, "SELECT owner, expires_at FROM "+LockTable+" WHERE name = ?", l.name).Scan(&owner, &expiresAt)
+	if err == nil {
+		count = 1
+		if time.Now().After(expiresAt) {
+			l.log(true, "lock %s held by %s expired at %s, taking over", l.name, owner, expiresAt.Format(time.RFC3339))
+			_, err = l.conn.ExecContext(ctx, "DELETE FROM "+LockTable+" WHERE name = ?", l.name)
+			count = 0
+		}
+	} else if err == sql.ErrNoRows {
+		err = nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if count == 0 {
+		now := time.Now().UTC()
+		_, err = l.conn.ExecContext(ctx, "INSERT INTO "+LockTable+" (name, owner, locked_at, expires_at) VALUES (?, ?, ?, ?)",
+			l.name, l.owner, now, now.Add(l.lease))
+		if err != nil {
+			return false, err
+		}
+	}
+	_, err = l.conn.ExecContext(ctx, "COMMIT")
+	return count == 0 && err == nil, err
+}
+
+// heartbeat extend sqlite lock row expiry until lock is released
+func (l *Lock) heartbeat() {
+	defer close(l.done)
+	ticker := time.NewTicker(l.lease / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			result, err := l.conn.ExecContext(context.Background(), "UPDATE "+LockTable+" SET expires_at = ? WHERE name = ? AND owner = ?",
+				time.Now().UTC().Add(l.lease), l.name, l.owner)
+			var n int64
+			if err == nil {
+				n, err = result.RowsAffected()
+			}
+			if err != nil {
+				l.log(true, "lock %s expiry extension failed: %s", l.name, err)
+			} else if n == 0 {
+				l.log(true, "lock %s is lost by %s", l.name, l.owner)
+			}
+		}
+	}
+}
+
+// holder description of lock holder
+func (l *Lock) holder(ctx context.Context) string {
+	var holder string
+	var err error
+	switch l.dialect {
+	case "postgres":
+		key := godb.AdvisoryKey(l.name)
+		err = l.conn.QueryRowContext(ctx, `SELECT 'pid ' || a.pid || ' ' || COALESCE(a.usename, '') || '@' ||
+COALESCE(host(a.client_addr), 'local') || ' ' || COALESCE(a.application_name, '') || ' since ' || COALESCE(a.backend_start::text, '')
+FROM pg_catalog.pg_locks l JOIN pg_catalog.pg_stat_activity a ON a.pid = l.pid
+WHERE l.locktype = 'advisory' AND l.granted AND l.classid::bigint = $1 AND l.objid::bigint = $2 AND l.objsubid = 1
+LIMIT 1`, int64(uint64(key)>>32), int64(uint32(key))).Scan(&holder)
+	case "mysql":
+		err = l.conn.QueryRowContext(ctx, `SELECT CONCAT('connection ', p.id, ' ', p.user, '@', p.host, ' for ', p.time, 's')
+FROM information_schema.processlist p WHERE p.id = IS_USED_LOCK(?)`, l.name).Scan(&holder)
+	case "sqlite3":
+		var lockedAt time.Time
+		err = l.conn.QueryRowContext(ctx, "SELECT owner, locked_at FROM "+LockTable+" WHERE name = ?", l.name).Scan(&holder, &lockedAt)
+		holder += " since " + lockedAt.Format(time.RFC3339)
+	}
+	if err != nil {
+		return "unknown"
+	}
+	return holder
+}
+
+// isBusy sqlite database is locked by other connection. Attempt is retried
+func isBusy(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "database is locked")
+}
+
+// log lock message
+func (l *Lock) log(warn bool, format string, v ...interface{}) {
+	if l.dbo.Logger == nil {
+		return
+	}
+	if warn {
+		l.dbo.Logger.Warnf(format, v...)
+	} else {
+		l.dbo.Logger.Infof(format, v...)
+	}
+}
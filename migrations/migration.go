@@ -0,0 +1,117 @@
+package migrations
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidFileName sql file name does not match <version>_<name>.(up|down).sql
+	ErrInvalidFileName = errors.New("migrations: invalid file name")
+	// ErrDuplicateVersion several migrations have the same version
+	ErrDuplicateVersion = errors.New("migrations: duplicate version")
+	// ErrNoUp migration has down file only
+	ErrNoUp = errors.New("migrations: up file not found")
+	// ErrNoDown migration can not be rolled back
+	ErrNoDown = errors.New("migrations: down file not found")
+	// ErrModified applied migration file was edited
+	ErrModified = errors.New("migrations: applied migration is modified")
+	// ErrMissing applied migration file was removed
+	ErrMissing = errors.New("migrations: applied migration file not found")
+	// ErrUnknownVersion target version has no migration
+	ErrUnknownVersion = errors.New("migrations: unknown version")
+)
+
+// file name of migration
+var fileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration versioned schema change
+type Migration struct {
+	// Migration version. Migrations are applied in version order
+	Version int64
+	// Migration name
+	Name string
+	// Apply query
+	Up string
+	// Rollback query. Empty if migration has no down file
+	Down string
+	// Down file exists
+	HasDown bool
+}
+
+// Checksum sha256 of up query
+func (m Migration) Checksum() string {
+	sum := sha256.Sum256([]byte(m.Up))
+	return hex.EncodeToString(sum[:])
+}
+
+// String version and name
+func (m Migration) String() string {
+	return strconv.FormatInt(m.Version, 10) + "_" + m.Name
+}
+
+// Load read migrations from directory of file system ordered by version
+// Files must be named <version>_<name>.up.sql and <version>_<name>.down.sql, other files are ignored
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	if dir == "" {
+		dir = "."
+	}
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int64]*Migration)
+	ups := make(map[int64]bool)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidFileName, entry.Name())
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidFileName, entry.Name())
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("%w: %d", ErrDuplicateVersion, version)
+		}
+		if match[3] == "up" && !ups[version] {
+			m.Up = string(data)
+			ups[version] = true
+		} else if match[3] == "down" && !m.HasDown {
+			m.Down = string(data)
+			m.HasDown = true
+		} else {
+			return nil, fmt.Errorf("%w: %d", ErrDuplicateVersion, version)
+		}
+	}
+	result := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if !ups[m.Version] {
+			return nil, fmt.Errorf("%w: %s", ErrNoUp, m)
+		}
+		result = append(result, *m)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Version < result[j].Version
+	})
+	return result, nil
+}
@@ -0,0 +1,317 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"github.com/dimonrus/godb/v2"
+	"github.com/dimonrus/godb/v2/internal/sqlitetest"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func testFS() fstest.MapFS {
+	return fstest.MapFS{
+		"sql/1_users.up.sql":       {Data: []byte("CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)")},
+		"sql/1_users.down.sql":     {Data: []byte("DROP TABLE users")},
+		"sql/2_users_age.up.sql":   {Data: []byte("ALTER TABLE users ADD COLUMN age INTEGER")},
+		"sql/2_users_age.down.sql": {Data: []byte("ALTER TABLE users DROP COLUMN age")},
+		"sql/10_books.up.sql":      {Data: []byte("CREATE TABLE books (id INTEGER PRIMARY KEY);\nCREATE INDEX books_id ON books (id);")},
+		"sql/10_books.down.sql":    {Data: []byte("DROP TABLE books")},
+		"sql/README.md":            {Data: []byte("migrations")},
+	}
+}
+
+func TestLoad(t *testing.T) {
+	migrations, err := Load(testFS(), "sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 3 || migrations[0].Version != 1 || migrations[1].Version != 2 || migrations[2].Version != 10 {
+		t.Fatal("migrations must be ordered by version", migrations)
+	}
+	if migrations[2].Name != "books" || !migrations[2].HasDown || migrations[2].String() != "10_books" {
+		t.Fatal("wrong migration", migrations[2])
+	}
+	fsys := testFS()
+	fsys["sql/3_bad-name.sql"] = &fstest.MapFile{Data: []byte("SELECT 1")}
+	if _, err = Load(fsys, "sql"); !errors.Is(err, ErrInvalidFileName) {
+		t.Fatal("invalid file name must fail", err)
+	}
+	fsys = testFS()
+	fsys["sql/2_other.up.sql"] = &fstest.MapFile{Data: []byte("SELECT 1")}
+	if _, err = Load(fsys, "sql"); !errors.Is(err, ErrDuplicateVersion) {
+		t.Fatal("duplicate version must fail", err)
+	}
+	fsys = testFS()
+	fsys["sql/3_down.down.sql"] = &fstest.MapFile{Data: []byte("SELECT 1")}
+	if _, err = Load(fsys, "sql"); !errors.Is(err, ErrNoUp) {
+		t.Fatal("down without up must fail", err)
+	}
+}
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+	db := sqlitetest.Init(t, godb.Options{})
+	fsys := testFS()
+	m := &Migrator{DBO: db, FS: fsys, Dir: "sql"}
+	t.Run("cancelled", func(t *testing.T) {
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		if _, err := m.Applied(cancelled); !errors.Is(err, context.Canceled) {
+			t.Fatal("context must be used", err)
+		}
+	})
+	t.Run("up", func(t *testing.T) {
+		n, err := m.Up(ctx)
+		if err != nil || n != 3 {
+			t.Fatal("all migrations must be applied", n, err)
+		}
+		if ok, err := godb.ColumnExists(db, "users", "age", ""); err != nil || !ok {
+			t.Fatal("column must exist", err)
+		}
+		n, err = m.Up(ctx)
+		if err != nil || n != 0 {
+			t.Fatal("nothing to apply", n, err)
+		}
+		applied, err := m.Applied(ctx)
+		if err != nil || len(applied) != 3 || applied[2].Checksum != (Migration{Up: string(fsys["sql/10_books.up.sql"].Data)}).Checksum() {
+			t.Fatal("wrong history", applied, err)
+		}
+		if applied[0].AppliedAt.IsZero() {
+			t.Fatal("apply time must be set")
+		}
+	})
+	t.Run("to", func(t *testing.T) {
+		n, err := m.To(ctx, 1)
+		if err != nil || n != 2 {
+			t.Fatal("two migrations must be rolled back", n, err)
+		}
+		if ok, _ := godb.TableExists(db, "books", ""); ok {
+			t.Fatal("table must be dropped")
+		}
+		if ok, _ := godb.ColumnExists(db, "users", "age", ""); ok {
+			t.Fatal("column must be dropped")
+		}
+		n, err = m.To(ctx, 2)
+		if err != nil || n != 1 {
+			t.Fatal("one migration must be applied", n, err)
+		}
+		if _, err = m.To(ctx, 5); !errors.Is(err, ErrUnknownVersion) {
+			t.Fatal("unknown version must fail", err)
+		}
+		status, err := m.Status(ctx)
+		if err != nil || len(status) != 3 || !status[1].Applied || status[2].Applied {
+			t.Fatal("wrong status", status, err)
+		}
+	})
+	t.Run("failed", func(t *testing.T) {
+		fsys["sql/11_broken.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE authors (id INTEGER); INSERT INTO nothing VALUES (1)")}
+		defer delete(fsys, "sql/11_broken.up.sql")
+		n, err := m.Up(ctx)
+		if err == nil || n != 1 {
+			t.Fatal("broken migration must fail after books", n, err)
+		}
+		if ok, _ := godb.TableExists(db, "authors", ""); ok {
+			t.Fatal("broken migration must be rolled back")
+		}
+		status, _ := m.Status(ctx)
+		if status[3].Applied {
+			t.Fatal("broken migration must not be recorded")
+		}
+	})
+	t.Run("modified", func(t *testing.T) {
+		original := fsys["sql/1_users.up.sql"].Data
+		fsys["sql/1_users.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE users (id BIGINT PRIMARY KEY)")}
+		defer func() { fsys["sql/1_users.up.sql"] = &fstest.MapFile{Data: original} }()
+		if err := m.Verify(ctx); !errors.Is(err, ErrModified) {
+			t.Fatal("modified migration must be detected", err)
+		}
+		if _, err := m.Down(ctx); !errors.Is(err, ErrModified) {
+			t.Fatal("modified migration must block rollback", err)
+		}
+		m.AllowModified = true
+		defer func() { m.AllowModified = false }()
+		if _, err := m.Up(ctx); err != nil {
+			t.Fatal("modifications must be allowed", err)
+		}
+	})
+	t.Run("missing", func(t *testing.T) {
+		up, down := fsys["sql/10_books.up.sql"], fsys["sql/10_books.down.sql"]
+		delete(fsys, "sql/10_books.up.sql")
+		delete(fsys, "sql/10_books.down.sql")
+		defer func() { fsys["sql/10_books.up.sql"], fsys["sql/10_books.down.sql"] = up, down }()
+		if err := m.Verify(ctx); !errors.Is(err, ErrMissing) {
+			t.Fatal("missing migration must be detected", err)
+		}
+	})
+	t.Run("down", func(t *testing.T) {
+		if err := m.Verify(ctx); err != nil {
+			t.Fatal(err)
+		}
+		n, err := m.Down(ctx)
+		if err != nil || n != 1 {
+			t.Fatal("last migration must be rolled back", n, err)
+		}
+		n, err = m.To(ctx, 0)
+		if err != nil || n != 2 {
+			t.Fatal("all migrations must be rolled back", n, err)
+		}
+		if ok, _ := godb.TableExists(db, "users", ""); ok {
+			t.Fatal("table must be dropped")
+		}
+	})
+}
+
+func TestMigratorRawQueries(t *testing.T) {
+	ctx := context.Background()
+	db := sqlitetest.Init(t, godb.Options{
+		StatementCacheSize: 10,
+		QueryProcessor: func(query string) string {
+			return "broken " + query
+		},
+	})
+	m := &Migrator{DBO: db, FS: testFS(), Dir: "sql", NoLock: true}
+	n, err := m.Up(ctx)
+	if err != nil || n != 3 {
+		t.Fatal("migrations must not be processed", n, err)
+	}
+	var count int
+	err = db.DB.QueryRow("SELECT count(*) FROM sqlite_master WHERE name = 'books_id'").Scan(&count)
+	if err != nil || count != 1 {
+		t.Fatal("all statements of migration must be executed", count, err)
+	}
+	if stats := db.StatementCacheStats(); stats.Misses != 0 {
+		t.Fatal("migrations must not use statement cache", stats)
+	}
+}
+
+func TestLock(t *testing.T) {
+	ctx := context.Background()
+	db := sqlitetest.Init(t, godb.Options{})
+	t.Run("timeout", func(t *testing.T) {
+		lock, err := AcquireLock(ctx, db, "test", time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = AcquireLock(ctx, db, "test", time.Millisecond*100)
+		if !errors.Is(err, ErrLockTimeout) || !strings.Contains(err.Error(), lock.owner) {
+			t.Fatal("lock must be held by first owner", err)
+		}
+		other, err := AcquireLock(ctx, db, "other", time.Second)
+		if err != nil {
+			t.Fatal("other lock must be acquired", err)
+		}
+		if err = other.Release(); err != nil {
+			t.Fatal(err)
+		}
+		if err = lock.Release(); err != nil {
+			t.Fatal(err)
+		}
+		lock, err = AcquireLock(ctx, db, "test", time.Second)
+		if err != nil {
+			t.Fatal("released lock must be acquired", err)
+		}
+		if err = lock.Release(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("lease", func(t *testing.T) {
+		lock, err := AcquireLock(ctx, db, "lease", time.Millisecond*100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer lock.Release()
+		var lockedAt, expiresAt time.Time
+		err = db.DB.QueryRow("SELECT locked_at, expires_at FROM "+LockTable+" WHERE name = ?", "lease").Scan(&lockedAt, &expiresAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expiresAt.Sub(lockedAt) != lockLease {
+			t.Fatal("row must expire after lease instead of wait timeout", expiresAt.Sub(lockedAt))
+		}
+	})
+	t.Run("expired", func(t *testing.T) {
+		lockLease = time.Millisecond * 300
+		defer func() { lockLease = time.Second * 30 }()
+		lock, err := AcquireLock(ctx, db, "expired", time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// holder keeps lock alive longer than its lease
+		time.Sleep(time.Millisecond * 500)
+		if _, err = AcquireLock(ctx, db, "expired", time.Millisecond*100); !errors.Is(err, ErrLockTimeout) {
+			t.Fatal("lock must be extended by holder", err)
+		}
+		if err = lock.Release(); err != nil {
+			t.Fatal(err)
+		}
+		// row of crashed process
+		_, err = db.Exec("INSERT INTO "+LockTable+" (name, owner, locked_at, expires_at) VALUES (?, ?, ?, ?)",
+			"expired", "crashed:1", time.Now().UTC().Add(-time.Minute), time.Now().UTC().Add(-time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+		lock, err = AcquireLock(ctx, db, "expired", time.Second)
+		if err != nil {
+			t.Fatal("expired lock must be taken over", err)
+		}
+		if err = lock.Release(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("busy", func(t *testing.T) {
+		busy := sqlitetest.Init(t, godb.Options{SessionInit: []string{"PRAGMA busy_timeout = 0"}})
+		// idle connection for lock, opening new one waits for exclusive transaction
+		idle, err := busy.DB.Conn(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn, err := busy.DB.Conn(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		_ = idle.Close()
+		if _, err = conn.ExecContext(ctx, "BEGIN EXCLUSIVE"); err != nil {
+			t.Fatal(err)
+		}
+		go func() {
+			time.Sleep(time.Millisecond * 200)
+			_, _ = conn.ExecContext(ctx, "ROLLBACK")
+		}()
+		lock, err := AcquireLock(ctx, busy, "busy", time.Second*5)
+		if err != nil {
+			t.Fatal("busy database must be retried", err)
+		}
+		if err = lock.Release(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("concurrent_up", func(t *testing.T) {
+		fsys := testFS()
+		var wg sync.WaitGroup
+		var m sync.Mutex
+		total := 0
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				migrator := &Migrator{DBO: db, FS: fsys, Dir: "sql", LockTimeout: time.Second * 10}
+				n, err := migrator.Up(ctx)
+				if err != nil {
+					t.Error(err)
+				}
+				m.Lock()
+				total += n
+				m.Unlock()
+			}()
+		}
+		wg.Wait()
+		if total != 3 {
+			t.Fatal("each migration must be applied once", total)
+		}
+	})
+}
@@ -0,0 +1,327 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"github.com/dimonrus/godb/v2"
+	"io/fs"
+	"time"
+)
+
+// DefaultTable migration history table name
+const DefaultTable = "schema_migrations"
+
+// Applied migration history record
+type Applied struct {
+	// Migration version
+	Version int64
+	// Migration name
+	Name string
+	// Checksum of up query at apply time
+	Checksum string
+	// Time of apply
+	AppliedAt time.Time
+}
+
+// Status of migration
+type Status struct {
+	// Migration from files. Only version and name are set if file is missing
+	Migration
+	// Migration is applied
+	Applied bool
+	// Time of apply
+	AppliedAt time.Time
+	// Up file was edited after apply
+	Modified bool
+	// Migration is applied but file is missing
+	Missing bool
+}
+
+// Migrator apply migrations from file system. Each migration is applied in own transaction
+// Multiple statements in one file require multiStatements=true dsn param for mysql
+// Mysql commits DDL statements implicitly, so failed migration may be applied partially
+// Mysql requires parseTime=true dsn param to read history
+// Queries are executed as is, query processor, statement cache and statement timeouts of DBO are not applied
+type Migrator struct {
+	// Database object
+	DBO *godb.DBO
+	// Migration files, e.g. os.DirFS or embed.FS
+	FS fs.FS
+	// Directory of migration files in FS. Default is root
+	Dir string
+	// History table. Default DefaultTable
+	Table string
+	// Run migrations even if applied migrations are modified or missing
+	AllowModified bool
+	// Lock name. Default is history table name
+	LockName string
+	// Maximum time to wait for lock held by other instance. Default DefaultLockTimeout
+	LockTimeout time.Duration
+	// Run migrations without lock
+	NoLock bool
+}
+
+// CreateTable create history table
+func (m *Migrator) CreateTable(ctx context.Context) error {
+	_, err := m.DBO.DB.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+m.table()+` (
+	version BIGINT NOT NULL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	checksum CHAR(64) NOT NULL,
+	applied_at TIMESTAMP NOT NULL
+)`)
+	return err
+}
+
+// Applied history of applied migrations ordered by version
+func (m *Migrator) Applied(ctx context.Context) ([]Applied, error) {
+	err := m.CreateTable(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := m.DBO.DB.QueryContext(ctx, "SELECT version, name, checksum, applied_at FROM "+m.table()+" ORDER BY version")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []Applied
+	for rows.Next() {
+		a := Applied{}
+		err = rows.Scan(&a.Version, &a.Name, &a.Checksum, &a.AppliedAt)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, a)
+	}
+	return result, rows.Err()
+}
+
+// Status of all migrations ordered by version. Missing applied migrations are included
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	migrations, applied, err := m.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Status, 0, len(migrations))
+	i := 0
+	for _, a := range applied {
+		for ; i < len(migrations) && migrations[i].Version < a.Version; i++ {
+			result = append(result, Status{Migration: migrations[i]})
+		}
+		s := Status{Applied: true, AppliedAt: a.AppliedAt}
+		if i < len(migrations) && migrations[i].Version == a.Version {
+			s.Migration = migrations[i]
+			s.Modified = migrations[i].Checksum() != a.Checksum
+			i++
+		} else {
+			s.Migration = Migration{Version: a.Version, Name: a.Name}
+			s.Missing = true
+		}
+		result = append(result, s)
+	}
+	for ; i < len(migrations); i++ {
+		result = append(result, Status{Migration: migrations[i]})
+	}
+	return result, nil
+}
+
+// Verify check that applied migrations are neither modified nor missing
+func (m *Migrator) Verify(ctx context.Context) error {
+	status, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	return verify(status)
+}
+
+// Up apply all pending migrations. Count of applied migrations is returned
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	lock, err := m.lock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer m.unlock(lock)
+	status, err := m.plan(ctx)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, s := range status {
+		if s.Applied {
+			continue
+		}
+		if err = m.apply(ctx, s.Migration, true); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Down rollback last applied migration. Count of rolled back migrations is returned
+func (m *Migrator) Down(ctx context.Context) (int, error) {
+	lock, err := m.lock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer m.unlock(lock)
+	status, err := m.plan(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for i := len(status) - 1; i >= 0; i-- {
+		if status[i].Applied {
+			return 1, m.rollback(ctx, status[i])
+		}
+	}
+	return 0, nil
+}
+
+// To migrate to version. Migrations above version are rolled back, pending migrations up to version are applied
+// Version 0 rolls back all migrations. Count of applied and rolled back migrations is returned
+func (m *Migrator) To(ctx context.Context, version int64) (int, error) {
+	lock, err := m.lock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer m.unlock(lock)
+	status, err := m.plan(ctx)
+	if err != nil {
+		return 0, err
+	}
+	known := version == 0
+	for _, s := range status {
+		known = known || s.Version == version
+	}
+	if !known {
+		return 0, fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+	count := 0
+	for i := len(status) - 1; i >= 0; i-- {
+		if status[i].Applied && status[i].Version > version {
+			if err = m.rollback(ctx, status[i]); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+	for _, s := range status {
+		if !s.Applied && s.Version <= version {
+			if err = m.apply(ctx, s.Migration, true); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+	return count, nil
+}
+
+// load migration files and applied history
+func (m *Migrator) load(ctx context.Context) ([]Migration, []Applied, error) {
+	migrations, err := Load(m.FS, m.Dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	applied, err := m.Applied(ctx)
+	return migrations, applied, err
+}
+
+// plan status of migrations verified unless modifications are allowed
+func (m *Migrator) plan(ctx context.Context) ([]Status, error) {
+	status, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !m.AllowModified {
+		err = verify(status)
+	}
+	return status, err
+}
+
+// rollback applied migration
+func (m *Migrator) rollback(ctx context.Context, s Status) error {
+	if s.Missing {
+		return fmt.Errorf("%w: %s", ErrMissing, s.Migration)
+	}
+	if !s.HasDown {
+		return fmt.Errorf("%w: %s", ErrNoDown, s.Migration)
+	}
+	return m.apply(ctx, s.Migration, false)
+}
+
+// apply migration up or down and update history in one transaction
+func (m *Migrator) apply(ctx context.Context, migration Migration, up bool) error {
+	tx, err := m.DBO.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	query, action := migration.Down, "rolled back"
+	if up {
+		query, action = migration.Up, "applied"
+	}
+	_, err = tx.ExecContext(ctx, query)
+	if err == nil {
+		d := m.DBO.ConnType()
+		if up {
+			_, err = tx.ExecContext(ctx, "INSERT INTO "+m.table()+" (version, name, checksum, applied_at) VALUES ("+
+				godb.Placeholder(d, 1)+", "+godb.Placeholder(d, 2)+", "+godb.Placeholder(d, 3)+", "+godb.Placeholder(d, 4)+")",
+				migration.Version, migration.Name, migration.Checksum(), time.Now().UTC())
+		} else {
+			_, err = tx.ExecContext(ctx, "DELETE FROM "+m.table()+" WHERE version = "+godb.Placeholder(d, 1), migration.Version)
+		}
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("migration %s: %w", migration, err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("migration %s: %w", migration, err)
+	}
+	if m.DBO.Logger != nil {
+		m.DBO.Logger.Infof("migration %s %s", migration, action)
+	}
+	return nil
+}
+
+// lock acquire migration lock unless lock is disabled
+func (m *Migrator) lock(ctx context.Context) (*Lock, error) {
+	if m.NoLock {
+		return nil, nil
+	}
+	name := m.LockName
+	if name == "" {
+		name = m.table()
+	}
+	return AcquireLock(ctx, m.DBO, name, m.LockTimeout)
+}
+
+// unlock release migration lock
+func (m *Migrator) unlock(lock *Lock) {
+	if lock == nil {
+		return
+	}
+	if err := lock.Release(); err != nil && m.DBO.Logger != nil {
+		m.DBO.Logger.Errorln("migration lock: " + err.Error())
+	}
+}
+
+// table name
+func (m *Migrator) table() string {
+	if m.Table == "" {
+		return DefaultTable
+	}
+	return m.Table
+}
+
+// verify status has no modified or missing migrations
+func verify(status []Status) error {
+	for _, s := range status {
+		if s.Modified {
+			return fmt.Errorf("%w: %s", ErrModified, s.Migration)
+		}
+		if s.Missing {
+			return fmt.Errorf("%w: %s", ErrMissing, s.Migration)
+		}
+	}
+	return nil
+}
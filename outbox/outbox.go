@@ -0,0 +1,283 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/dimonrus/godb/v2"
+	"time"
+)
+
+const (
+	// DefaultTable outbox table name
+	DefaultTable = "outbox"
+	// DefaultBackoff delay before retry of failed message
+	DefaultBackoff = time.Second
+	// DefaultMaxBackoff maximum delay between retries of failed message
+	DefaultMaxBackoff = time.Minute * 5
+)
+
+// ErrUnsupportedDialect dialect has no outbox schema
+var ErrUnsupportedDialect = errors.New("outbox: unsupported dialect")
+
+// Message outbox message
+type Message struct {
+	// Message identifier
+	Id int64
+	// Message topic
+	Topic string
+	// Message payload
+	Payload []byte
+	// Count of failed publish attempts
+	Attempts int
+}
+
+// Publisher publish outbox messages
+type Publisher interface {
+	// Publish message
+	Publish(ctx context.Context, message Message) error
+}
+
+// PublisherFunc function implements Publisher
+type PublisherFunc func(ctx context.Context, message Message) error
+
+// Publish message
+func (f PublisherFunc) Publish(ctx context.Context, message Message) error {
+	return f(ctx, message)
+}
+
+// Schema statements creating outbox table for dialect
+func Schema(dialect string) ([]string, error) {
+	return SchemaTable(dialect, DefaultTable)
+}
+
+// SchemaTable statements creating outbox table with custom name for dialect
+func SchemaTable(dialect string, table string) ([]string, error) {
+	switch dialect {
+	case "postgres":
+		return []string{
+			`CREATE TABLE IF NOT EXISTS ` + table + ` (
+	id BIGSERIAL PRIMARY KEY,
+	topic TEXT NOT NULL,
+	payload BYTEA,
+	attempts INT NOT NULL DEFAULT 0,
+	last_error TEXT,
+	next_attempt_at TIMESTAMPTZ,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	published_at TIMESTAMPTZ
+)`,
+			`CREATE INDEX IF NOT EXISTS ` + table + `_unpublished_idx ON ` + table + ` (id) WHERE published_at IS NULL`,
+		}, nil
+	case "mysql":
+		return []string{
+			`CREATE TABLE IF NOT EXISTS ` + table + ` (
+	id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+	topic VARCHAR(255) NOT NULL,
+	payload LONGBLOB,
+	attempts INT NOT NULL DEFAULT 0,
+	last_error TEXT,
+	next_attempt_at TIMESTAMP NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	published_at TIMESTAMP NULL,
+	INDEX ` + table + `_unpublished_idx (published_at, id)
+)`,
+		}, nil
+	case "sqlite3":
+		return []string{
+			`CREATE TABLE IF NOT EXISTS ` + table + ` (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	topic TEXT NOT NULL,
+	payload BLOB,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT,
+	next_attempt_at DATETIME,
+	created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	published_at DATETIME
+)`,
+			`CREATE INDEX IF NOT EXISTS ` + table + `_unpublished_idx ON ` + table + ` (id) WHERE published_at IS NULL`,
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedDialect, dialect)
+}
+
+// CreateTable create outbox table
+func CreateTable(q godb.Queryer, table string) error {
+	statements, err := SchemaTable(godb.Dialect(q), table)
+	if err != nil {
+		return err
+	}
+	for _, statement := range statements {
+		_, err = q.Exec(statement)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Enqueue write message to outbox table within transaction
+func Enqueue(tx *godb.SqlTx, topic string, payload []byte) error {
+	return EnqueueTable(tx, DefaultTable, topic, payload)
+}
+
+// EnqueueTable write message to outbox table with custom name
+func EnqueueTable(q godb.Queryer, table string, topic string, payload []byte) error {
+	d := godb.Dialect(q)
+	_, err := q.Exec("INSERT INTO "+table+" (topic, payload) VALUES ("+godb.Placeholder(d, 1)+", "+godb.Placeholder(d, 2)+")", topic, payload)
+	return err
+}
+
+// Relay poll outbox table and pass messages to publisher
+type Relay struct {
+	// Database object
+	DBO *godb.DBO
+	// Messages publisher
+	Publisher Publisher
+	// Outbox table. Default DefaultTable
+	Table string
+	// Messages processed in one transaction. Default 100
+	BatchSize int
+	// Poll interval when there are no messages. Default 1s
+	Interval time.Duration
+	// Maximum publish attempts. Messages are skipped after that. Default 10
+	MaxAttempts int
+	// Delay before retry of failed message. Doubles after each failed attempt. Default DefaultBackoff
+	Backoff time.Duration
+	// Maximum delay between retries. Default DefaultMaxBackoff
+	MaxBackoff time.Duration
+}
+
+// Run poll outbox until context is done
+func (r *Relay) Run(ctx context.Context) error {
+	interval := r.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	for {
+		n, failed, err := r.process(ctx)
+		if err != nil && r.DBO.Logger != nil {
+			r.DBO.Logger.Errorln("outbox relay: " + err.Error())
+		}
+		// full batch without failures means there are more messages to publish
+		if err == nil && failed == 0 && n == r.batchSize() {
+			if ctx.Err() != nil {
+				return nil
+			}
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Process publish one batch of messages. Count of processed messages is returned
+// Rows are locked with FOR UPDATE SKIP LOCKED on postgres and mysql so several relays can work concurrently
+// Failed message is retried after backoff
+func (r *Relay) Process(ctx context.Context) (int, error) {
+	n, _, err := r.process(ctx)
+	return n, err
+}
+
+// process publish one batch of messages. Count of processed and failed messages is returned
+func (r *Relay) process(ctx context.Context) (int, int, error) {
+	tx, err := r.DBO.BeginContext(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	now := time.Now().UTC()
+	messages, err := r.fetch(tx, now)
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, 0, err
+	}
+	d := godb.Dialect(tx)
+	failed := 0
+	for _, message := range messages {
+		err = r.Publisher.Publish(ctx, message)
+		if err == nil {
+			_, err = tx.Exec("UPDATE "+r.table()+" SET published_at = CURRENT_TIMESTAMP WHERE id = "+godb.Placeholder(d, 1), message.Id)
+		} else {
+			failed++
+			_, err = tx.Exec("UPDATE "+r.table()+" SET attempts = attempts + 1, last_error = "+godb.Placeholder(d, 1)+
+				", next_attempt_at = "+godb.Placeholder(d, 2)+" WHERE id = "+godb.Placeholder(d, 3),
+				err.Error(), now.Add(r.backoff(message.Attempts)), message.Id)
+		}
+		if err != nil {
+			_ = tx.Rollback()
+			return 0, 0, err
+		}
+	}
+	return len(messages), failed, tx.Commit()
+}
+
+// fetch batch of unpublished messages ready for next attempt
+func (r *Relay) fetch(tx *godb.SqlTx, now time.Time) ([]Message, error) {
+	d := godb.Dialect(tx)
+	query := "SELECT id, topic, payload, attempts FROM " + r.table() +
+		" WHERE published_at IS NULL AND attempts < " + godb.Placeholder(d, 1) +
+		" AND (next_attempt_at IS NULL OR next_attempt_at <= " + godb.Placeholder(d, 2) + ")" +
+		" ORDER BY id LIMIT " + godb.Placeholder(d, 3)
+	if d == "postgres" || d == "mysql" {
+		query += " FOR UPDATE SKIP LOCKED"
+	}
+	rows, err := tx.Query(query, r.maxAttempts(), now, r.batchSize())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var messages []Message
+	for rows.Next() {
+		m := Message{}
+		err = rows.Scan(&m.Id, &m.Topic, &m.Payload, &m.Attempts)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
+
+// table name
+func (r *Relay) table() string {
+	if r.Table == "" {
+		return DefaultTable
+	}
+	return r.Table
+}
+
+// batch size
+func (r *Relay) batchSize() int {
+	if r.BatchSize <= 0 {
+		return 100
+	}
+	return r.BatchSize
+}
+
+// maximum attempts
+func (r *Relay) maxAttempts() int {
+	if r.MaxAttempts <= 0 {
+		return 10
+	}
+	return r.MaxAttempts
+}
+
+// backoff delay after failed attempt
+func (r *Relay) backoff(attempts int) time.Duration {
+	delay, maxDelay := r.Backoff, r.MaxBackoff
+	if delay <= 0 {
+		delay = DefaultBackoff
+	}
+	if maxDelay <= 0 {
+		maxDelay = DefaultMaxBackoff
+	}
+	for i := 0; i < attempts && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	return delay
+}
@@ -0,0 +1,126 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"github.com/dimonrus/godb/v2"
+	"github.com/dimonrus/godb/v2/internal/sqlitetest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// publisher collects messages and fails for topic "fail"
+type testPublisher struct {
+	m        sync.Mutex
+	messages []Message
+}
+
+func (p *testPublisher) Publish(ctx context.Context, message Message) error {
+	if message.Topic == "fail" {
+		return errors.New("broker is down")
+	}
+	p.m.Lock()
+	p.messages = append(p.messages, message)
+	p.m.Unlock()
+	return nil
+}
+
+func (p *testPublisher) count() int {
+	p.m.Lock()
+	defer p.m.Unlock()
+	return len(p.messages)
+}
+
+func TestRelay(t *testing.T) {
+	db := sqlitetest.Init(t, godb.Options{})
+	err := CreateTable(db, DefaultTable)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// rolled back message is not enqueued
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Enqueue(tx, "user.created", []byte(`{"id":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tx.Rollback()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err = db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, topic := range []string{"user.created", "fail", "user.updated"} {
+		err = Enqueue(tx, topic, []byte(`{"id":2}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publisher := &testPublisher{}
+	relay := &Relay{DBO: db, Publisher: publisher, BatchSize: 2, MaxAttempts: 3, Interval: time.Millisecond * 10, Backoff: time.Millisecond}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*300)
+	defer cancel()
+	err = relay.Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if publisher.count() != 2 || publisher.messages[0].Topic != "user.created" || publisher.messages[1].Topic != "user.updated" {
+		t.Fatal("wrong published messages", publisher.messages)
+	}
+	var attempts int
+	var lastError string
+	err = db.QueryRow("SELECT attempts, last_error FROM outbox WHERE topic = 'fail'").Scan(&attempts, &lastError)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 3 || lastError != "broker is down" {
+		t.Fatal("failed message must be retried up to max attempts", attempts, lastError)
+	}
+	n, err := relay.Process(context.Background())
+	if err != nil || n != 0 {
+		t.Fatal("there must be no messages to publish", n, err)
+	}
+}
+
+func TestRelay_Backoff(t *testing.T) {
+	db := sqlitetest.Init(t, godb.Options{})
+	err := CreateTable(db, DefaultTable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = EnqueueTable(db, DefaultTable, "fail", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	relay := &Relay{DBO: db, Publisher: &testPublisher{}, Backoff: time.Millisecond * 100}
+	n, err := relay.Process(context.Background())
+	if err != nil || n != 1 {
+		t.Fatal("message must be processed", n, err)
+	}
+	n, err = relay.Process(context.Background())
+	if err != nil || n != 0 {
+		t.Fatal("failed message must wait for backoff", n, err)
+	}
+	time.Sleep(time.Millisecond * 150)
+	n, err = relay.Process(context.Background())
+	if err != nil || n != 1 {
+		t.Fatal("failed message must be retried after backoff", n, err)
+	}
+	relay.MaxBackoff = time.Second
+	for attempts, expected := range []time.Duration{time.Millisecond * 100, time.Millisecond * 200, time.Millisecond * 400, time.Millisecond * 800, time.Second, time.Second} {
+		if d := relay.backoff(attempts); d != expected {
+			t.Fatal("wrong backoff", attempts, d)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package godb
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+)
+
+// ErrSessionExecNotSupported driver connection can not execute queries without prepared statement
+var ErrSessionExecNotSupported = errors.New("driver connection does not support exec")
+
+// SessionConn new physical connection passed to OnConnect callback
+type SessionConn struct {
+	driver.Conn
+	dialect string
+}
+
+// ConnType get connection type
+func (c *SessionConn) ConnType() string {
+	return c.dialect
+}
+
+// Exec query on physical connection
+func (c *SessionConn) Exec(ctx context.Context, query string, args ...interface{}) error {
+	values, err := c.namedValues(args)
+	if err != nil {
+		return err
+	}
+	if e, ok := c.Conn.(driver.ExecerContext); ok {
+		_, err := e.ExecContext(ctx, query, values)
+		if err != driver.ErrSkip {
+			return err
+		}
+	}
+	var stmt driver.Stmt
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = p.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	if s, ok := stmt.(driver.StmtExecContext); ok {
+		_, err = s.ExecContext(ctx, values)
+		return err
+	}
+	return ErrSessionExecNotSupported
+}
+
+// namedValues convert arguments with connection value checker or default converter as database/sql does
+func (c *SessionConn) namedValues(args []interface{}) ([]driver.NamedValue, error) {
+	checker, _ := c.Conn.(driver.NamedValueChecker)
+	values := make([]driver.NamedValue, 0, len(args))
+	for i, arg := range args {
+		nv := driver.NamedValue{Ordinal: i + 1, Value: arg}
+		err := driver.ErrSkip
+		if checker != nil {
+			err = checker.CheckNamedValue(&nv)
+		}
+		if err == driver.ErrSkip {
+			nv.Value, err = driver.DefaultParameterConverter.ConvertValue(arg)
+		}
+		if err == driver.ErrRemoveArgument {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %w", i+1, err)
+		}
+		values = append(values, nv)
+	}
+	return values, nil
+}
+
+// sessionConnector run session init on every new physical connection
+type sessionConnector struct {
+	driver.Connector
+	dialect string
+	options Options
+}
+
+// Connect open physical connection and apply session settings
+// Connection is closed if any statement fails
+func (c *sessionConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.init(ctx, &SessionConn{Conn: conn, dialect: c.dialect}); err != nil {
+		_ = conn.Close()
+		if c.options.Logger != nil {
+			c.options.Logger.Errorln(err.Error())
+		}
+		return nil, err
+	}
+	return conn, nil
+}
+
+// init execute session statements and callback
+func (c *sessionConnector) init(ctx context.Context, conn *SessionConn) error {
+	for _, query := range c.options.SessionInit {
+		if err := conn.Exec(ctx, query); err != nil {
+			return fmt.Errorf("session init %q: %w", query, err)
+		}
+	}
+	if c.options.OnConnect != nil {
+		if err := c.options.OnConnect(ctx, conn); err != nil {
+			return fmt.Errorf("session init: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package godb
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// logger collects error messages
+type errorLogger struct {
+	*testLogger
+}
+
+func (l errorLogger) Errorln(v ...interface{}) {
+	l.Println(v...)
+}
+
+func TestSessionInit(t *testing.T) {
+	t.Run("statements", func(t *testing.T) {
+		var m sync.Mutex
+		connections := 0
+		db := initSqlite(t, Options{
+			SessionInit: []string{"PRAGMA cache_size = -1234"},
+			OnConnect: func(ctx context.Context, conn *SessionConn) error {
+				if conn.ConnType() != "sqlite3" {
+					t.Error("wrong connection type", conn.ConnType())
+				}
+				m.Lock()
+				connections++
+				m.Unlock()
+				return conn.Exec(ctx, "CREATE TEMP TABLE connect_test (id INTEGER)")
+			},
+		})
+		ctx := context.Background()
+		// hold several connections at once so pool opens new ones
+		conns := make([]*SqlConn, 3)
+		for i := range conns {
+			conn, err := db.Conn(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			conns[i] = conn
+		}
+		for _, conn := range conns {
+			var size int
+			if err := conn.QueryRow("PRAGMA cache_size").Scan(&size); err != nil || size != -1234 {
+				t.Fatal("session setting must be applied", size, err)
+			}
+			exists, err := TableExists(conn, "connect_test", "temp")
+			if err != nil || !exists {
+				t.Fatal("callback must be called on connection", err)
+			}
+			conn.Close()
+		}
+		m.Lock()
+		defer m.Unlock()
+		if connections < len(conns) {
+			t.Fatal("callback must be called for every connection", connections)
+		}
+	})
+	t.Run("failed_statement", func(t *testing.T) {
+		logger := errorLogger{newTestLogger()}
+		_, err := DBO{
+			Options:    Options{Logger: logger, SessionInit: []string{"SET unknown = 1"}},
+			Connection: NewSqlite(t),
+		}.Init()
+		if err == nil || !strings.Contains(err.Error(), "session init") {
+			t.Fatal("session init error must be returned", err)
+		}
+		if logger.count() == 0 || !strings.Contains(logger.messages[0], "SET unknown = 1") {
+			t.Fatal("session init error must be logged")
+		}
+	})
+	t.Run("failed_callback", func(t *testing.T) {
+		errCallback := errors.New("callback failed")
+		db := initSqlite(t, Options{LazyConnect: true, OnConnect: func(ctx context.Context, conn *SessionConn) error {
+			return errCallback
+		}})
+		if _, err := db.Exec("SELECT 1"); !errors.Is(err, errCallback) {
+			t.Fatal("callback error must be returned", err)
+		}
+	})
+}
+
+func TestSessionConn_namedValues(t *testing.T) {
+	conn := &SessionConn{Conn: &fakeConn{d: &fakeDriver{}}}
+	values, err := conn.namedValues([]interface{}{5, time.Second, "utc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values[0].Value != int64(5) || values[1].Value != int64(time.Second) || values[2].Value != "utc" || values[2].Ordinal != 3 {
+		t.Fatal("arguments must be converted to driver values", values)
+	}
+	if _, err = conn.namedValues([]interface{}{struct{}{}}); err == nil {
+		t.Fatal("unsupported argument must fail")
+	}
+}
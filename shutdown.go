@@ -0,0 +1,242 @@
+package godb
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+)
+
+// ErrDBOClosed database object is shut down
+var ErrDBOClosed = errors.New("database object is closed")
+
+// dboState tracks in-flight queries and active transactions
+type dboState struct {
+	m      sync.Mutex
+	closed bool
+	// closed on shutdown
+	done chan struct{}
+	// in-flight queries
+	queries sync.WaitGroup
+	// active transactions
+	transactions map[*SqlTx]struct{}
+	// active transactions counter
+	active sync.WaitGroup
+	// dedicated connections held by callers
+	conns map[io.Closer]struct{}
+	// held connections counter
+	held sync.WaitGroup
+	// advisory locks released on shutdown
+	locks map[io.Closer]struct{}
+}
+
+// Create state
+func newDboState() *dboState {
+	return &dboState{
+		transactions: make(map[*SqlTx]struct{}),
+		conns:        make(map[io.Closer]struct{}),
+		locks:        make(map[io.Closer]struct{}),
+		done:         make(chan struct{}),
+	}
+}
+
+// acquire register in-flight query
+func (s *dboState) acquire() error {
+	if s == nil {
+		return nil
+	}
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.closed {
+		return ErrDBOClosed
+	}
+	s.queries.Add(1)
+	return nil
+}
+
+// release in-flight query
+func (s *dboState) release() {
+	if s != nil {
+		s.queries.Done()
+	}
+}
+
+// addTx register active transaction
+func (s *dboState) addTx(tx *SqlTx) error {
+	if s == nil {
+		return nil
+	}
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.closed {
+		return ErrDBOClosed
+	}
+	s.transactions[tx] = struct{}{}
+	s.active.Add(1)
+	return nil
+}
+
+// removeTx unregister finished transaction
+func (s *dboState) removeTx(tx *SqlTx) {
+	if s == nil {
+		return
+	}
+	s.m.Lock()
+	defer s.m.Unlock()
+	if _, ok := s.transactions[tx]; ok {
+		delete(s.transactions, tx)
+		s.active.Done()
+	}
+}
+
+// addConn register dedicated connection. It is not an in-flight query because it may be held for a long time
+func (s *dboState) addConn(c io.Closer) error {
+	if s == nil {
+		return nil
+	}
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.closed {
+		return ErrDBOClosed
+	}
+	s.conns[c] = struct{}{}
+	s.held.Add(1)
+	return nil
+}
+
+// removeConn unregister released connection
+func (s *dboState) removeConn(c io.Closer) {
+	if s == nil {
+		return
+	}
+	s.m.Lock()
+	defer s.m.Unlock()
+	if _, ok := s.conns[c]; ok {
+		delete(s.conns, c)
+		s.held.Done()
+	}
+}
+
+// heldConns list of held connections
+func (s *dboState) heldConns() []io.Closer {
+	s.m.Lock()
+	defer s.m.Unlock()
+	result := make([]io.Closer, 0, len(s.conns))
+	for c := range s.conns {
+		result = append(result, c)
+	}
+	return result
+}
+
+// addLock register held advisory lock. Shutdown does not wait for it
+func (s *dboState) addLock(l io.Closer) error {
+	if s == nil {
+		return nil
+	}
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.closed {
+		return ErrDBOClosed
+	}
+	s.locks[l] = struct{}{}
+	return nil
+}
+
+// removeLock unregister released lock
+func (s *dboState) removeLock(l io.Closer) {
+	if s == nil {
+		return
+	}
+	s.m.Lock()
+	delete(s.locks, l)
+	s.m.Unlock()
+}
+
+// heldLocks list of held advisory locks
+func (s *dboState) heldLocks() []io.Closer {
+	s.m.Lock()
+	defer s.m.Unlock()
+	result := make([]io.Closer, 0, len(s.locks))
+	for l := range s.locks {
+		result = append(result, l)
+	}
+	return result
+}
+
+// close stop accepting new queries and transactions
+func (s *dboState) close() error {
+	if s == nil {
+		return nil
+	}
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.closed {
+		return ErrDBOClosed
+	}
+	s.closed = true
+	close(s.done)
+	return nil
+}
+
+// activeTransactions list of active transactions
+func (s *dboState) activeTransactions() []*SqlTx {
+	s.m.Lock()
+	defer s.m.Unlock()
+	result := make([]*SqlTx, 0, len(s.transactions))
+	for tx := range s.transactions {
+		result = append(result, tx)
+	}
+	return result
+}
+
+// Shutdown stop accepting new queries and transactions, wait for in-flight queries, active transactions
+// and held connections until context is done, rollback remaining transactions, close remaining connections,
+// release held advisory locks, flush log messages and close database
+// Shutdown does not wait for advisory locks, release them before shutdown to finish critical sections
+// Context error is returned if transactions were rolled back or connections were closed by deadline
+func (dbo *DBO) Shutdown(ctx context.Context) error {
+	err := dbo.state.close()
+	if err != nil {
+		return err
+	}
+	if dbo.state != nil {
+		finished := make(chan struct{})
+		go func() {
+			dbo.state.queries.Wait()
+			dbo.state.active.Wait()
+			dbo.state.held.Wait()
+			close(finished)
+		}()
+		select {
+		case <-finished:
+		case <-ctx.Done():
+			err = ctx.Err()
+			for _, tx := range dbo.state.activeTransactions() {
+				if e := tx.Rollback(); e != nil && dbo.Logger != nil {
+					dbo.Logger.Errorln(e.Error())
+				}
+			}
+			for _, c := range dbo.state.heldConns() {
+				if e := c.Close(); e != nil && dbo.Logger != nil {
+					dbo.Logger.Errorln(e.Error())
+				}
+			}
+		}
+		for _, l := range dbo.state.heldLocks() {
+			if e := l.Close(); e != nil && dbo.Logger != nil {
+				dbo.Logger.Errorln(e.Error())
+			}
+		}
+	}
+	if dbo.TransactionPool != nil {
+		dbo.TransactionPool.Reset()
+	}
+	dbo.stmtCache.reset()
+	if dbo.queryLog != nil {
+		dbo.queryLog.close()
+	}
+	if e := dbo.DB.Close(); e != nil && err == nil {
+		err = e
+	}
+	return err
+}
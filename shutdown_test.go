@@ -0,0 +1,129 @@
+package godb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/dimonrus/gocli"
+	"sync"
+	"testing"
+	"time"
+)
+
+// logger collects printed messages
+type testLogger struct {
+	gocli.Logger
+	m        sync.Mutex
+	messages []string
+}
+
+func (l *testLogger) Println(v ...interface{}) {
+	l.m.Lock()
+	l.messages = append(l.messages, fmt.Sprint(v...))
+	l.m.Unlock()
+}
+
+func (l *testLogger) count() int {
+	l.m.Lock()
+	defer l.m.Unlock()
+	return len(l.messages)
+}
+
+func newTestLogger() *testLogger {
+	return &testLogger{Logger: gocli.NewLogger(gocli.LoggerConfig{})}
+}
+
+func TestShutdown(t *testing.T) {
+	t.Run("wait_transaction", func(t *testing.T) {
+		lg := newTestLogger()
+		db := initSqlite(t, Options{Debug: true, Logger: lg, LogPolicy: LogBlock})
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		go func() {
+			time.Sleep(time.Millisecond * 50)
+			_, _ = tx.Exec("CREATE TABLE shutdown (id INTEGER)")
+			_ = tx.Commit()
+		}()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+		for i := 0; i < 100; i++ {
+			_, err = db.Exec("SELECT 1")
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		err = db.Shutdown(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lg.count() != 101 {
+			t.Fatal("all log messages must be flushed", lg.count())
+		}
+		_, err = db.Exec("SELECT 1")
+		if !errors.Is(err, ErrDBOClosed) {
+			t.Fatal("exec must fail after shutdown", err)
+		}
+		_, err = db.Begin()
+		if !errors.Is(err, ErrDBOClosed) {
+			t.Fatal("begin must fail after shutdown", err)
+		}
+		if !errors.Is(db.Shutdown(ctx), ErrDBOClosed) {
+			t.Fatal("second shutdown must fail")
+		}
+	})
+	t.Run("rollback_on_deadline", func(t *testing.T) {
+		db := initSqlite(t, Options{Debug: true})
+		db.TransactionPool = NewTransactionPool()
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		db.TransactionPool.Set(GenTransactionId(), tx)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+		defer cancel()
+		err = db.Shutdown(ctx)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatal("shutdown must report deadline", err)
+		}
+		if db.TransactionPool.Count() != 0 {
+			t.Fatal("transaction pool must be empty")
+		}
+		_, err = tx.Exec("SELECT 1")
+		if err == nil {
+			t.Fatal("transaction must be rolled back")
+		}
+	})
+	t.Run("close_conn_on_deadline", func(t *testing.T) {
+		db := initSqlite(t, Options{})
+		conn := &heldConn{state: db.state}
+		if err := db.state.addConn(conn); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec("SELECT 1"); err != nil {
+			t.Fatal("held connection is not an in-flight query", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+		defer cancel()
+		err := db.Shutdown(ctx)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatal("shutdown must report deadline", err)
+		}
+		if !conn.closed {
+			t.Fatal("held connection must be closed by deadline")
+		}
+	})
+}
+
+// connection held until shutdown
+type heldConn struct {
+	state  *dboState
+	closed bool
+}
+
+func (c *heldConn) Close() error {
+	c.closed = true
+	c.state.removeConn(c)
+	return nil
+}
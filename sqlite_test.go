@@ -0,0 +1,16 @@
+package godb
+
+import "testing"
+
+// Sqlite fixture of internal/sqlitetest. Set by external test package because sqlitetest imports godb
+var (
+	// NewSqlite sqlite connection to database file in temporary directory of test
+	NewSqlite func(t testing.TB) Connection
+	// InitSqlite database object with new sqlite connection. Database is closed on test cleanup
+	InitSqlite func(t testing.TB, options Options) *DBO
+)
+
+// init sqlite database in temporary directory
+func initSqlite(t testing.TB, options Options) *DBO {
+	return InitSqlite(t, options)
+}
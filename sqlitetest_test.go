@@ -0,0 +1,14 @@
+package godb_test
+
+import (
+	"github.com/dimonrus/godb/v2"
+	"github.com/dimonrus/godb/v2/internal/sqlitetest"
+	"testing"
+)
+
+func init() {
+	godb.NewSqlite = func(t testing.TB) godb.Connection {
+		return sqlitetest.New(t)
+	}
+	godb.InitSqlite = sqlitetest.Init
+}
@@ -0,0 +1,264 @@
+package godb
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// StatementCacheStats statement cache counters
+type StatementCacheStats struct {
+	// Count of cached statements
+	Size int
+	// Queries executed with cached statement
+	Hits uint64
+	// Statements prepared on first use
+	Misses uint64
+	// Statements closed because cache is full
+	Evictions uint64
+	// Statements closed because of invalid cached plan
+	Invalidations uint64
+}
+
+// stmtCacheEntry cached statement
+type stmtCacheEntry struct {
+	query string
+	stmt  *sql.Stmt
+	// count of executions in progress
+	refs int
+	// removed from cache, closed when last execution released
+	removed bool
+}
+
+// stmtCache LRU cache of prepared statements keyed by processed query
+type stmtCache struct {
+	// counters. First fields for atomic alignment
+	hits          uint64
+	misses        uint64
+	evictions     uint64
+	invalidations uint64
+	m             sync.Mutex
+	size          int
+	items         map[string]*list.Element
+	lru           *list.List
+	prepare       func(query string) (*sql.Stmt, error)
+}
+
+// Create statement cache. Nil is returned if cache is disabled
+func newStmtCache(size int, prepare func(query string) (*sql.Stmt, error)) *stmtCache {
+	if size <= 0 {
+		return nil
+	}
+	return &stmtCache{
+		size:    size,
+		items:   make(map[string]*list.Element),
+		lru:     list.New(),
+		prepare: prepare,
+	}
+}
+
+// accepts check if query is executed with cached statement
+// Queries without arguments, e.g. DDL or SET, and multi statement queries are executed directly
+func (c *stmtCache) accepts(query string, args []interface{}) bool {
+	return c != nil && len(args) > 0 && !multiStatement(query)
+}
+
+// isInvalidCachedPlan postgres rejects prepared statement after schema change of queried tables
+func isInvalidCachedPlan(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "cached plan must not change result type")
+}
+
+// get cached statement or prepare new one. Entry must be released after use
+func (c *stmtCache) get(query string) (*stmtCacheEntry, error) {
+	c.m.Lock()
+	if el, ok := c.items[query]; ok {
+		c.lru.MoveToFront(el)
+		entry := el.Value.(*stmtCacheEntry)
+		entry.refs++
+		c.m.Unlock()
+		atomic.AddUint64(&c.hits, 1)
+		return entry, nil
+	}
+	c.m.Unlock()
+	atomic.AddUint64(&c.misses, 1)
+	stmt, err := c.prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	c.m.Lock()
+	if el, ok := c.items[query]; ok {
+		// prepared concurrently
+		c.lru.MoveToFront(el)
+		entry := el.Value.(*stmtCacheEntry)
+		entry.refs++
+		c.m.Unlock()
+		_ = stmt.Close()
+		return entry, nil
+	}
+	entry := &stmtCacheEntry{query: query, stmt: stmt, refs: 1}
+	c.items[query] = c.lru.PushFront(entry)
+	var evicted []*sql.Stmt
+	for c.lru.Len() > c.size {
+		if s := c.remove(c.lru.Back()); s != nil {
+			evicted = append(evicted, s)
+		}
+		atomic.AddUint64(&c.evictions, 1)
+	}
+	c.m.Unlock()
+	closeStmts(evicted)
+	return entry, nil
+}
+
+// remove element from cache. Statement is returned if it can be closed immediately
+func (c *stmtCache) remove(el *list.Element) *sql.Stmt {
+	entry := el.Value.(*stmtCacheEntry)
+	c.lru.Remove(el)
+	delete(c.items, entry.query)
+	entry.removed = true
+	if entry.refs == 0 {
+		return entry.stmt
+	}
+	return nil
+}
+
+// release entry after execution. Removed statement is closed by last execution
+func (c *stmtCache) release(entry *stmtCacheEntry) {
+	c.m.Lock()
+	entry.refs--
+	closeIt := entry.removed && entry.refs == 0
+	c.m.Unlock()
+	if closeIt {
+		_ = entry.stmt.Close()
+	}
+}
+
+// invalidate remove entry from cache
+func (c *stmtCache) invalidate(entry *stmtCacheEntry) {
+	c.m.Lock()
+	var stmt *sql.Stmt
+	if el, ok := c.items[entry.query]; ok && el.Value == entry {
+		stmt = c.remove(el)
+		atomic.AddUint64(&c.invalidations, 1)
+	}
+	c.m.Unlock()
+	if stmt != nil {
+		_ = stmt.Close()
+	}
+}
+
+// reset remove all statements from cache
+func (c *stmtCache) reset() {
+	if c == nil {
+		return
+	}
+	c.m.Lock()
+	var stmts []*sql.Stmt
+	for c.lru.Len() > 0 {
+		if s := c.remove(c.lru.Back()); s != nil {
+			stmts = append(stmts, s)
+		}
+	}
+	c.m.Unlock()
+	closeStmts(stmts)
+}
+
+// stats of cache
+func (c *stmtCache) stats() StatementCacheStats {
+	if c == nil {
+		return StatementCacheStats{}
+	}
+	c.m.Lock()
+	size := c.lru.Len()
+	c.m.Unlock()
+	return StatementCacheStats{
+		Size:          size,
+		Hits:          atomic.LoadUint64(&c.hits),
+		Misses:        atomic.LoadUint64(&c.misses),
+		Evictions:     atomic.LoadUint64(&c.evictions),
+		Invalidations: atomic.LoadUint64(&c.invalidations),
+	}
+}
+
+// do run function with cached statement
+// Statement is invalidated on invalid cached plan error and function is retried once if retry is allowed
+func (c *stmtCache) do(query string, retry bool, fn func(stmt *sql.Stmt) error) error {
+	for {
+		entry, err := c.get(query)
+		if err != nil {
+			return err
+		}
+		err = fn(entry.stmt)
+		c.release(entry)
+		if !isInvalidCachedPlan(err) {
+			return err
+		}
+		c.invalidate(entry)
+		if !retry {
+			return err
+		}
+		retry = false
+	}
+}
+
+// exec query with cached statement. Statement is bound to transaction if tx is not nil
+func (c *stmtCache) exec(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (result sql.Result, err error) {
+	err = c.do(query, tx == nil, func(stmt *sql.Stmt) error {
+		stmt, closeTxStmt := bindStmt(ctx, tx, stmt)
+		defer closeTxStmt()
+		result, err = stmt.ExecContext(ctx, args...)
+		return err
+	})
+	return
+}
+
+// query rows with cached statement. Statement is bound to transaction if tx is not nil
+func (c *stmtCache) query(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (rows *sql.Rows, err error) {
+	err = c.do(query, tx == nil, func(stmt *sql.Stmt) error {
+		stmt, closeTxStmt := bindStmt(ctx, tx, stmt)
+		defer closeTxStmt()
+		rows, err = stmt.QueryContext(ctx, args...)
+		return err
+	})
+	return
+}
+
+// queryRow query row with cached statement. Nil is returned if statement can not be prepared
+func (c *stmtCache) queryRow(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (row *sql.Row) {
+	_ = c.do(query, tx == nil, func(stmt *sql.Stmt) error {
+		stmt, closeTxStmt := bindStmt(ctx, tx, stmt)
+		defer closeTxStmt()
+		row = stmt.QueryRowContext(ctx, args...)
+		return row.Err()
+	})
+	return
+}
+
+// bindStmt rebind statement to transaction. Transaction statement must be closed after use
+// Rows keep transaction statement open until they are closed
+func bindStmt(ctx context.Context, tx *sql.Tx, stmt *sql.Stmt) (*sql.Stmt, func()) {
+	if tx == nil {
+		return stmt, func() {}
+	}
+	txStmt := tx.StmtContext(ctx, stmt)
+	return txStmt, func() { _ = txStmt.Close() }
+}
+
+// close statements
+func closeStmts(stmts []*sql.Stmt) {
+	for _, stmt := range stmts {
+		_ = stmt.Close()
+	}
+}
+
+// StatementCacheStats counters of statement cache. Zero stats are returned if cache is disabled
+func (dbo *DBO) StatementCacheStats() StatementCacheStats {
+	return dbo.stmtCache.stats()
+}
+
+// ResetStatementCache close all cached statements. Useful after schema changes
+func (dbo *DBO) ResetStatementCache() {
+	dbo.stmtCache.reset()
+}
@@ -0,0 +1,130 @@
+package godb
+
+import (
+	"database/sql"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestStatementCache(t *testing.T) {
+	db := initSqlite(t, Options{StatementCacheSize: 2})
+	_, err := db.Exec("CREATE TABLE cache_test (id INTEGER, name TEXT)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Run("reuse", func(t *testing.T) {
+		before := db.StatementCacheStats()
+		for i := 0; i < 5; i++ {
+			if _, err = db.Exec("INSERT INTO cache_test (id, name) VALUES (?, ?)", i, "name"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		stats := db.StatementCacheStats()
+		if stats.Misses-before.Misses != 1 || stats.Hits-before.Hits != 4 {
+			t.Fatal("statement must be prepared once", stats)
+		}
+	})
+	t.Run("evict", func(t *testing.T) {
+		queries := []string{
+			"SELECT count(*) FROM cache_test WHERE id = ?",
+			"SELECT count(*) FROM cache_test WHERE id > ?",
+			"SELECT count(*) FROM cache_test WHERE id < ?",
+		}
+		var wg sync.WaitGroup
+		for i := 0; i < 30; i++ {
+			wg.Add(1)
+			go func(query string) {
+				defer wg.Done()
+				var count int
+				if err := db.QueryRow(query, 2).Scan(&count); err != nil {
+					t.Error(err)
+				}
+			}(queries[i%len(queries)])
+		}
+		wg.Wait()
+		stats := db.StatementCacheStats()
+		if stats.Size != 2 || stats.Evictions == 0 {
+			t.Fatal("old statements must be evicted", stats)
+		}
+	})
+	t.Run("transaction", func(t *testing.T) {
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 3; i++ {
+			if _, err = tx.Exec("INSERT INTO cache_test (id, name) VALUES (?, ?)", 10+i, "tx"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		rows, err := tx.Query("SELECT id FROM cache_test WHERE name = ?", "tx")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var count int
+		for rows.Next() {
+			count++
+		}
+		if rows.Err() != nil || count != 3 {
+			t.Fatal("wrong rows", count, rows.Err())
+		}
+		rows.Close()
+		if err = tx.Rollback(); err != nil {
+			t.Fatal(err)
+		}
+		if err = db.QueryRow("SELECT count(*) FROM cache_test WHERE name = ?", "tx").Scan(&count); err != nil || count != 0 {
+			t.Fatal("transaction must be rolled back", count, err)
+		}
+	})
+	t.Run("invalidate", func(t *testing.T) {
+		before := db.StatementCacheStats()
+		calls := 0
+		err := db.stmtCache.do("SELECT 1", true, func(stmt *sql.Stmt) error {
+			calls++
+			if calls == 1 {
+				return errors.New("pq: cached plan must not change result type")
+			}
+			return nil
+		})
+		if err != nil || calls != 2 {
+			t.Fatal("must retry with new statement", calls, err)
+		}
+		stats := db.StatementCacheStats()
+		if stats.Invalidations-before.Invalidations != 1 || stats.Misses-before.Misses != 2 {
+			t.Fatal("statement must be prepared again", stats)
+		}
+	})
+	t.Run("multi_statement", func(t *testing.T) {
+		before := db.StatementCacheStats()
+		_, err = db.Exec("CREATE TABLE cache_multi_a (id INTEGER); CREATE TABLE cache_multi_b (id INTEGER)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ok, err := TableExists(db, "cache_multi_b", "")
+		if err != nil || !ok {
+			t.Fatal("second statement must be executed", err)
+		}
+		if _, err = db.Exec("INSERT INTO cache_multi_a (id) VALUES (?); INSERT INTO cache_multi_b (id) VALUES (1)", 1); err != nil {
+			t.Fatal(err)
+		}
+		var count int
+		if err = db.QueryRow("SELECT count(*) FROM cache_multi_b WHERE id = ?", 1).Scan(&count); err != nil || count != 1 {
+			t.Fatal("second statement with args must be executed", count, err)
+		}
+		stats := db.StatementCacheStats()
+		if stats.Misses-before.Misses != 2 {
+			t.Fatal("only single statements with args must be cached", stats)
+		}
+	})
+	t.Run("reset", func(t *testing.T) {
+		db.ResetStatementCache()
+		if db.StatementCacheStats().Size != 0 {
+			t.Fatal("cache must be empty")
+		}
+		var count int
+		if err = db.QueryRow("SELECT count(*) FROM cache_test").Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
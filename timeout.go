@@ -0,0 +1,132 @@
+package godb
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeout in milliseconds rounded up. Server settings have millisecond precision
+func timeoutMillis(d time.Duration) int64 {
+	ms := int64(d / time.Millisecond)
+	if d%time.Millisecond != 0 {
+		ms++
+	}
+	return ms
+}
+
+// statementTimeout server side timeout limited by context deadline. Zero if neither is set
+func statementTimeout(ctx context.Context, d time.Duration) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return d
+	}
+	remaining := time.Until(deadline)
+	if remaining < time.Millisecond {
+		// zero disables timeout on server
+		remaining = time.Millisecond
+	}
+	if d <= 0 || remaining < d {
+		return remaining
+	}
+	return d
+}
+
+// MaxExecutionTime add mysql MAX_EXECUTION_TIME optimizer hint to SELECT query
+// Other statements are returned unchanged because mysql supports hint only for read only SELECT
+func MaxExecutionTime(query string, d time.Duration) string {
+	if d <= 0 {
+		return query
+	}
+	trimmed := strings.TrimLeft(query, " \t\r\n")
+	if len(trimmed) <= 6 || !strings.EqualFold(trimmed[:6], "SELECT") {
+		return query
+	}
+	switch trimmed[6] {
+	case ' ', '\t', '\r', '\n':
+	default:
+		return query
+	}
+	i := len(query) - len(trimmed) + 6
+	return query[:i] + " /*+ MAX_EXECUTION_TIME(" + strconv.FormatInt(timeoutMillis(d), 10) + ") */" + query[i:]
+}
+
+// SetStatementTimeout server side timeout of each next statement in transaction. Zero disables timeout
+// Postgres statement_timeout is set until the end of transaction
+// Mysql MAX_EXECUTION_TIME hint is added to SELECT queries of transaction
+func (tx *SqlTx) SetStatementTimeout(d time.Duration) error {
+	if d < 0 {
+		d = 0
+	}
+	switch tx.ConnType() {
+	case "postgres":
+		_, err := tx.Exec("SET LOCAL statement_timeout = " + strconv.FormatInt(timeoutMillis(d), 10))
+		return err
+	case "mysql":
+		tx.m.Lock()
+		tx.statementTimeout = d
+		tx.m.Unlock()
+		return nil
+	}
+	return ErrUnsupportedDialect
+}
+
+// timeout hint of mysql query. Must be called under transaction lock
+func (tx *SqlTx) timeoutHint(query string) string {
+	if tx.statementTimeout <= 0 {
+		return query
+	}
+	return MaxExecutionTime(query, tx.statementTimeout)
+}
+
+// WithStatementTimeout run function in transaction with server side statement timeout. See SqlTx.SetStatementTimeout
+// Timeout is limited by context deadline, context deadline is used if timeout is not set
+// Transaction is committed if function succeeds
+func (dbo *DBO) WithStatementTimeout(ctx context.Context, d time.Duration, fn func(tx *SqlTx) error) error {
+	tx, err := dbo.BeginContext(ctx, &TxOptions{StatementTimeout: statementTimeout(ctx, d)})
+	if err != nil {
+		return err
+	}
+	return finishTimeout(tx, fn)
+}
+
+// ExecTimeout run query with server side statement timeout limited by context deadline
+// Mysql has no execution time limit for statements other than SELECT
+func (dbo *DBO) ExecTimeout(ctx context.Context, d time.Duration, query string, args ...interface{}) (sql.Result, error) {
+	var result sql.Result
+	err := dbo.WithStatementTimeout(ctx, d, func(tx *SqlTx) (err error) {
+		result, err = tx.Exec(query, args...)
+		return err
+	})
+	return result, err
+}
+
+// WithStatementTimeout run function in transaction on connection with server side statement timeout
+func (c *SqlConn) WithStatementTimeout(ctx context.Context, d time.Duration, fn func(tx *SqlTx) error) error {
+	tx, err := c.BeginContext(ctx, &TxOptions{StatementTimeout: statementTimeout(ctx, d)})
+	if err != nil {
+		return err
+	}
+	return finishTimeout(tx, fn)
+}
+
+// ExecTimeout run query on connection with server side statement timeout limited by context deadline
+func (c *SqlConn) ExecTimeout(ctx context.Context, d time.Duration, query string, args ...interface{}) (sql.Result, error) {
+	var result sql.Result
+	err := c.WithStatementTimeout(ctx, d, func(tx *SqlTx) (err error) {
+		result, err = tx.Exec(query, args...)
+		return err
+	})
+	return result, err
+}
+
+// finishTimeout commit transaction if function succeeds, rollback otherwise
+func finishTimeout(tx *SqlTx, fn func(tx *SqlTx) error) error {
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,157 @@
+package godb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// database object of fake driver
+func initFake(t *testing.T, dialect string) (*DBO, *fakeDriver) {
+	d := &fakeDriver{}
+	db := &DBO{
+		DB:         sql.OpenDB(d),
+		Connection: &fakeDbConnection{dialect: dialect},
+		state:      newDboState(),
+	}
+	t.Cleanup(func() {
+		db.DB.Close()
+	})
+	return db, d
+}
+
+func TestMaxExecutionTime(t *testing.T) {
+	cases := []struct {
+		query    string
+		timeout  time.Duration
+		expected string
+	}{
+		{"SELECT * FROM users", time.Second * 2, "SELECT /*+ MAX_EXECUTION_TIME(2000) */ * FROM users"},
+		{"\n  select id FROM users", time.Microsecond, "\n  select /*+ MAX_EXECUTION_TIME(1) */ id FROM users"},
+		{"SELECT 1", 0, "SELECT 1"},
+		{"UPDATE users SET name = ''", time.Second, "UPDATE users SET name = ''"},
+		{"SELECTED", time.Second, "SELECTED"},
+	}
+	for _, c := range cases {
+		if q := MaxExecutionTime(c.query, c.timeout); q != c.expected {
+			t.Errorf("wrong query %q, expected %q", q, c.expected)
+		}
+	}
+}
+
+func TestSqlTx_SetStatementTimeout(t *testing.T) {
+	t.Run("postgres", func(t *testing.T) {
+		db, server := initFake(t, "postgres")
+		tx, err := db.BeginContext(context.Background(), &TxOptions{StatementTimeout: time.Millisecond * 1500})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tx.Rollback()
+		if q := server.last(1); len(q) != 1 || q[0] != "SET LOCAL statement_timeout = 1500" {
+			t.Fatal("timeout must be set in transaction", q)
+		}
+		if err = tx.SetStatementTimeout(0); err != nil {
+			t.Fatal(err)
+		}
+		rows, err := tx.Query("SELECT 1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows.Close()
+		if q := server.last(2); q[0] != "SET LOCAL statement_timeout = 0" || q[1] != "SELECT 1" {
+			t.Fatal("timeout must be disabled", q)
+		}
+	})
+	t.Run("mysql", func(t *testing.T) {
+		db, server := initFake(t, "mysql")
+		tx, err := db.BeginContext(context.Background(), &TxOptions{StatementTimeout: time.Second})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tx.Rollback()
+		rows, err := tx.Query("SELECT 1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows.Close()
+		if _, err = tx.Exec("DELETE FROM users"); err != nil {
+			t.Fatal(err)
+		}
+		q := server.last(3)
+		if q[0] != "BEGIN" || q[1] != "SELECT /*+ MAX_EXECUTION_TIME(1000) */ 1" || q[2] != "DELETE FROM users" {
+			t.Fatal("hint must be added to select", q)
+		}
+	})
+	t.Run("unsupported", func(t *testing.T) {
+		db := initSqlite(t, Options{})
+		if _, err := db.BeginContext(context.Background(), &TxOptions{StatementTimeout: time.Second}); err != ErrUnsupportedDialect {
+			t.Fatal("sqlite has no statement timeout", err)
+		}
+		if len(db.state.activeTransactions()) != 0 {
+			t.Fatal("failed transaction must be removed")
+		}
+	})
+}
+
+func TestDBO_WithStatementTimeout(t *testing.T) {
+	t.Run("postgres", func(t *testing.T) {
+		db, d := initFake(t, "postgres")
+		if _, err := db.ExecTimeout(context.Background(), time.Second*2, "DELETE FROM users"); err != nil {
+			t.Fatal(err)
+		}
+		q := d.last(4)
+		if len(q) != 4 || q[0] != "BEGIN" || q[1] != "SET LOCAL statement_timeout = 2000" || q[2] != "DELETE FROM users" || q[3] != "COMMIT" {
+			t.Fatal("query must run in transaction with timeout", q)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*500)
+		defer cancel()
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		failed := errors.New("failed")
+		err = conn.WithStatementTimeout(ctx, 0, func(tx *SqlTx) error {
+			return failed
+		})
+		if err != failed {
+			t.Fatal("function error must be returned", err)
+		}
+		q = d.last(3)
+		var ms int
+		if _, err = fmt.Sscanf(q[1], "SET LOCAL statement_timeout = %d", &ms); err != nil || ms <= 0 || ms > 500 {
+			t.Fatal("timeout must be limited by context deadline", q[1])
+		}
+		if q[2] != "ROLLBACK" {
+			t.Fatal("transaction must be rolled back", q)
+		}
+	})
+	t.Run("mysql", func(t *testing.T) {
+		db, d := initFake(t, "mysql")
+		err := db.WithStatementTimeout(context.Background(), time.Second, func(tx *SqlTx) error {
+			rows, err := tx.Query("SELECT 1")
+			if err != nil {
+				return err
+			}
+			return rows.Close()
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if q := d.last(2); q[0] != "SELECT /*+ MAX_EXECUTION_TIME(1000) */ 1" || q[1] != "COMMIT" {
+			t.Fatal("hint must be added to select", q)
+		}
+	})
+	t.Run("no_timeout", func(t *testing.T) {
+		db, d := initFake(t, "postgres")
+		if _, err := db.ExecTimeout(context.Background(), 0, "DELETE FROM users"); err != nil {
+			t.Fatal(err)
+		}
+		if q := d.last(3); q[0] != "BEGIN" || q[1] != "DELETE FROM users" {
+			t.Fatal("timeout must not be set", q)
+		}
+	})
+}
@@ -1,49 +1,526 @@
 package godb
 
 import (
-	"github.com/dimonrus/gohelp"
+	"context"
+	"crypto/rand"
+	"errors"
+	"github.com/dimonrus/gocli"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 )
 
 // TransactionId transaction identifier
 type TransactionId string
 
+var (
+	// ErrPoolFull transaction pool reached maximum number of transactions
+	ErrPoolFull = errors.New("transaction pool is full")
+	// ErrTransactionIdExists other transaction is registered with the same identifier
+	ErrTransactionIdExists = errors.New("transaction id already exists")
+	// ErrInvalidTransactionId transaction identifier has wrong format
+	ErrInvalidTransactionId = errors.New("invalid transaction id")
+)
+
 // TransactionPool transaction pool
 type TransactionPool struct {
-	transactions map[TransactionId]*SqlTx
-	m            sync.RWMutex
+	transactions map[TransactionId]*poolEntry
+	// maximum number of transactions. 0 - unlimited
+	limit int
+	// identifier generator
+	generate func() TransactionId
+	// identifier validator
+	validate func(id TransactionId) error
+	m        sync.RWMutex
+}
+
+// pool entry
+type poolEntry struct {
+	tx    *SqlTx
+	owner string
+}
+
+// info transaction metadata
+func (e *poolEntry) info(id TransactionId) TransactionInfo {
+	info := TransactionInfo{Id: id, Owner: e.owner, Tx: e.tx}
+	if e.tx != nil && e.tx.transaction != nil {
+		info.StartedAt = e.tx.transaction.StartedAt
+		info.Age = time.Since(info.StartedAt)
+		info.TTL = e.tx.transaction.ttl()
+		info.State = e.tx.transaction.State()
+	}
+	return info
+}
+
+// TransactionInfo pooled transaction metadata
+type TransactionInfo struct {
+	// Transaction identifier
+	Id TransactionId
+	// Transaction start time
+	StartedAt time.Time
+	// Time since transaction start
+	Age time.Duration
+	// Time to live
+	TTL time.Duration
+	// Owner label
+	Owner string
+	// Lifecycle state
+	State TxState
+	// Transaction
+	Tx *SqlTx
 }
 
+// TxState transaction lifecycle state
+type TxState int
+
+const (
+	// TxActive transaction is in progress
+	TxActive TxState = iota
+	// TxCommitted transaction is committed
+	TxCommitted
+	// TxRolledBack transaction is rolled back
+	TxRolledBack
+	// TxExpired transaction is rolled back because of TTL expiry
+	TxExpired
+)
+
+// String state name
+func (s TxState) String() string {
+	switch s {
+	case TxActive:
+		return "active"
+	case TxCommitted:
+		return "committed"
+	case TxRolledBack:
+		return "rolled back"
+	case TxExpired:
+		return "expired"
+	}
+	return "unknown"
+}
+
+var (
+	// ErrTxExpired transaction was rolled back because of TTL expiry
+	ErrTxExpired = errors.New("transaction expired")
+	// ErrTxCommitted transaction is already committed
+	ErrTxCommitted = errors.New("transaction already committed")
+	// ErrTxRolledBack transaction is already rolled back
+	ErrTxRolledBack = errors.New("transaction already rolled back")
+)
+
 // Transaction params
 type Transaction struct {
-	// Time to live in unix timestampt
+	// Time to live
 	// 0 - no TTL for transaction
-	TTL int
-	// Event on transaction done
-	done chan struct{}
+	TTL time.Duration
+	// Transaction start time
+	StartedAt time.Time
+	// context bound to transaction. Cancelled on TTL expiry and when parent context is done
+	ctx    context.Context
+	cancel context.CancelFunc
+	// context of begin
+	parent context.Context
+	// expiry timer
+	timer    *time.Timer
+	onAbort  func()
+	deadline time.Time
+	// lifecycle state
+	state TxState
+	// callbacks called once transaction is finished
+	done []func(state TxState)
+	// user hooks called after commit and rollback
+	commitHooks   []func() error
+	rollbackHooks []func(err error)
+	// reason of rollback
+	reason error
+	// errors of hooks called after finish
+	hookErr *HookError
+	// logger of hook panics
+	logger gocli.Logger
+	m      sync.Mutex
+}
+
+// Create transaction params with context cancelled on TTL expiry
+func newTransaction(ctx context.Context, ttl time.Duration) *Transaction {
+	t := &Transaction{TTL: ttl, StartedAt: time.Now(), parent: ctx}
+	t.ctx, t.cancel = context.WithCancel(ctx)
+	return t
+}
+
+// start TTL timer and watch context of begin. Callback is called on expiry or when context is done
+func (t *Transaction) start(onAbort func()) {
+	t.m.Lock()
+	defer t.m.Unlock()
+	t.onAbort = onAbort
+	if t.TTL > 0 {
+		t.deadline = t.StartedAt.Add(t.TTL)
+		t.timer = time.AfterFunc(time.Until(t.deadline), t.expire)
+	}
+	if t.parent.Done() != nil {
+		go t.watch()
+	}
+}
+
+// watch context of begin. Done context makes database/sql rollback the transaction, so it is rolled back with context error
+func (t *Transaction) watch() {
+	<-t.ctx.Done()
+	if err := t.parent.Err(); err != nil {
+		t.abort(TxRolledBack, err)
+	}
+}
+
+// expire active transaction. Cancelled context makes database/sql rollback the transaction
+func (t *Transaction) expire() {
+	t.abort(TxExpired, ErrTxExpired)
+}
+
+// abort active transaction rolled back by database/sql because its context is cancelled
+func (t *Transaction) abort(state TxState, reason error) {
+	t.m.Lock()
+	if t.state != TxActive {
+		t.m.Unlock()
+		return
+	}
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+	t.state = state
+	t.reason = reason
+	onAbort := t.onAbort
+	t.m.Unlock()
+	t.cancel()
+	if onAbort != nil {
+		onAbort()
+	}
+	t.notify(state)
+	t.runHooks()
+}
+
+// finish active transaction with target state. Repeated finish with the same state is no-op
+// Failed commit and commit after context of begin is done move transaction to rolled back state
+// Hooks are called after finish, their errors are logged and available with HookError
+func (t *Transaction) finish(target TxState, fn func() error) error {
+	t.m.Lock()
+	switch t.state {
+	case TxActive:
+	case target:
+		t.m.Unlock()
+		return nil
+	case TxCommitted:
+		t.m.Unlock()
+		return ErrTxCommitted
+	case TxRolledBack:
+		t.m.Unlock()
+		return ErrTxRolledBack
+	default:
+		t.m.Unlock()
+		return ErrTxExpired
+	}
+	if reason := t.parent.Err(); reason != nil {
+		// database/sql rolls back transaction of done context, finish is the same transition as watch makes
+		t.m.Unlock()
+		t.abort(TxRolledBack, reason)
+		if target == TxRolledBack {
+			return nil
+		}
+		return ErrTxRolledBack
+	}
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+	err := fn()
+	t.state = target
+	if err != nil && target == TxCommitted {
+		t.state = TxRolledBack
+	}
+	if t.state == TxRolledBack {
+		t.reason = err
+	}
+	state := t.state
+	t.m.Unlock()
+	t.cancel()
+	t.notify(state)
+	t.runHooks()
+	return err
+}
+
+// HookError errors of hooks called after transaction is finished. Nil if hooks succeeded
+func (t *Transaction) HookError() *HookError {
+	t.m.Lock()
+	defer t.m.Unlock()
+	return t.hookErr
+}
+
+// onDone add callback called once transaction is finished. Called immediately for finished transaction
+func (t *Transaction) onDone(fn func(state TxState)) {
+	t.m.Lock()
+	state := t.state
+	if state == TxActive {
+		t.done = append(t.done, fn)
+	}
+	t.m.Unlock()
+	if state != TxActive {
+		fn(state)
+	}
+}
+
+// notify finished transaction callbacks
+func (t *Transaction) notify(state TxState) {
+	t.m.Lock()
+	done := t.done
+	t.done = nil
+	t.m.Unlock()
+	for _, fn := range done {
+		fn(state)
+	}
+}
+
+// ttl current time to live
+func (t *Transaction) ttl() time.Duration {
+	t.m.Lock()
+	defer t.m.Unlock()
+	return t.TTL
+}
+
+// State current lifecycle state
+func (t *Transaction) State() TxState {
+	t.m.Lock()
+	defer t.m.Unlock()
+	return t.state
+}
+
+// Context bound to transaction. It is cancelled on TTL expiry and when context of begin is done
+func (t *Transaction) Context() context.Context {
+	return t.ctx
+}
+
+// Deadline time of TTL expiry. False if transaction has no TTL
+func (t *Transaction) Deadline() (time.Time, bool) {
+	t.m.Lock()
+	defer t.m.Unlock()
+	return t.deadline, !t.deadline.IsZero()
+}
+
+// Remaining time before TTL expiry. False if transaction has no TTL
+func (t *Transaction) Remaining() (time.Duration, bool) {
+	deadline, ok := t.Deadline()
+	if !ok {
+		return 0, false
+	}
+	remaining := time.Until(deadline)
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining, true
+}
+
+// Extend TTL deadline by duration. Transaction without TTL gets deadline from now
+func (t *Transaction) Extend(d time.Duration) error {
+	t.m.Lock()
+	defer t.m.Unlock()
+	switch t.state {
+	case TxCommitted:
+		return ErrTxCommitted
+	case TxRolledBack:
+		return ErrTxRolledBack
+	case TxExpired:
+		return ErrTxExpired
+	}
+	if t.timer == nil {
+		t.deadline = time.Now().Add(d)
+		t.TTL = t.deadline.Sub(t.StartedAt)
+		t.timer = time.AfterFunc(d, t.expire)
+		return nil
+	}
+	if !t.timer.Stop() {
+		return ErrTxExpired
+	}
+	t.deadline = t.deadline.Add(d)
+	t.TTL += d
+	t.timer.Reset(time.Until(t.deadline))
+	return nil
+}
+
+// Expired transaction was rolled back because of TTL expiry
+func (t *Transaction) Expired() bool {
+	return t.State() == TxExpired
 }
 
+// Crockford base32 alphabet of transaction identifiers
+const idAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// Length of transaction identifier
+const idLength = 26
+
+// Attempts to generate unused identifier
+const maxIdAttempts = 10
+
 // GenTransactionId Generate transaction id
+// Identifier is ULID: 48 bits of unix time in milliseconds and 80 bits of crypto random encoded with Crockford base32
+// Identifiers are sorted by creation time
 func GenTransactionId() TransactionId {
-	return TransactionId(gohelp.RandString(16))
+	var b [16]byte
+	ms := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+	for i := 5; i >= 0; i-- {
+		b[i] = byte(ms)
+		ms >>= 8
+	}
+	if _, err := rand.Read(b[6:]); err != nil {
+		panic(err)
+	}
+	var id [idLength]byte
+	// 128 bits are encoded by 5 bits from the most significant, first char holds 3 bits
+	var acc uint
+	var bits uint = 2
+	k := 0
+	for i := 0; i < len(b); i++ {
+		acc = acc<<8 | uint(b[i])
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			id[k] = idAlphabet[(acc>>bits)&31]
+			k++
+		}
+	}
+	return TransactionId(id[:])
+}
+
+// ValidateTransactionId check identifier is generated by GenTransactionId
+func ValidateTransactionId(id TransactionId) error {
+	if len(id) != idLength || id[0] > '7' {
+		return ErrInvalidTransactionId
+	}
+	for i := 0; i < len(id); i++ {
+		if strings.IndexByte(idAlphabet, id[i]) < 0 {
+			return ErrInvalidTransactionId
+		}
+	}
+	return nil
 }
 
 // Get transaction if exists
 func (p *TransactionPool) Get(id TransactionId) *SqlTx {
 	p.m.RLock()
-	tx := p.transactions[id]
+	defer p.m.RUnlock()
+	if e, ok := p.transactions[id]; ok {
+		return e.tx
+	}
+	return nil
+}
+
+// Info pooled transaction metadata
+func (p *TransactionPool) Info(id TransactionId) (TransactionInfo, bool) {
+	p.m.RLock()
+	defer p.m.RUnlock()
+	if e, ok := p.transactions[id]; ok {
+		return e.info(id), true
+	}
+	return TransactionInfo{}, false
+}
+
+// Set transaction. Pool limit is not checked, use Register to check it
+// ErrTransactionIdExists is returned if other transaction is registered with the same id, it is not replaced
+func (p *TransactionPool) Set(id TransactionId, tx *SqlTx) error {
+	p.m.Lock()
+	if e, ok := p.transactions[id]; ok && e.tx != tx {
+		p.m.Unlock()
+		return ErrTransactionIdExists
+	}
+	p.set(id, tx, "")
+	p.m.Unlock()
+	p.watch(id, tx)
+	return nil
+}
+
+// Register transaction with owner label
+// ErrPoolFull is returned if pool limit is reached, ErrTransactionIdExists if id is used by other transaction
+func (p *TransactionPool) Register(id TransactionId, tx *SqlTx, owner string) error {
+	p.m.Lock()
+	if e, ok := p.transactions[id]; ok {
+		if e.tx != tx {
+			p.m.Unlock()
+			return ErrTransactionIdExists
+		}
+	} else if p.limit > 0 && len(p.transactions) >= p.limit {
+		p.m.Unlock()
+		return ErrPoolFull
+	}
+	p.set(id, tx, owner)
+	p.m.Unlock()
+	p.watch(id, tx)
+	return nil
+}
+
+// Add register transaction with owner label under new generated identifier
+// ErrTransactionIdExists is returned if generator repeats used identifiers
+func (p *TransactionPool) Add(tx *SqlTx, owner string) (TransactionId, error) {
+	for i := 0; ; i++ {
+		id := p.NewId()
+		err := p.Register(id, tx, owner)
+		if err != ErrTransactionIdExists || i+1 >= maxIdAttempts {
+			return id, err
+		}
+	}
+}
+
+// NewId generate transaction identifier
+func (p *TransactionPool) NewId() TransactionId {
+	p.m.RLock()
+	generate := p.generate
 	p.m.RUnlock()
-	return tx
+	if generate == nil {
+		return GenTransactionId()
+	}
+	return generate()
 }
 
-// Set transaction
-func (p *TransactionPool) Set(id TransactionId, tx *SqlTx) *TransactionPool {
+// Validate check incoming transaction identifier
+func (p *TransactionPool) Validate(id TransactionId) error {
+	p.m.RLock()
+	generate, validate := p.generate, p.validate
+	p.m.RUnlock()
+	if validate != nil {
+		return validate(id)
+	}
+	if generate == nil {
+		return ValidateTransactionId(id)
+	}
+	if id == "" {
+		return ErrInvalidTransactionId
+	}
+	return nil
+}
+
+// SetIdGenerator set identifier generator and validator. Default are GenTransactionId and ValidateTransactionId
+// Custom generator without validator accepts any non-empty identifier
+func (p *TransactionPool) SetIdGenerator(generate func() TransactionId, validate func(id TransactionId) error) *TransactionPool {
 	p.m.Lock()
-	p.transactions[id] = tx
+	p.generate = generate
+	p.validate = validate
 	p.m.Unlock()
 	return p
 }
 
+// set pool entry
+func (p *TransactionPool) set(id TransactionId, tx *SqlTx, owner string) {
+	p.transactions[id] = &poolEntry{tx: tx, owner: owner}
+}
+
+// watch unregister transaction once it is finished
+func (p *TransactionPool) watch(id TransactionId, tx *SqlTx) {
+	if tx == nil || tx.transaction == nil {
+		return
+	}
+	tx.transaction.onDone(func(state TxState) {
+		p.m.Lock()
+		if e, ok := p.transactions[id]; ok && e.tx == tx {
+			delete(p.transactions, id)
+		}
+		p.m.Unlock()
+	})
+}
+
 // UnSet transaction
 func (p *TransactionPool) UnSet(id TransactionId) *TransactionPool {
 	p.m.Lock()
@@ -55,19 +532,95 @@ func (p *TransactionPool) UnSet(id TransactionId) *TransactionPool {
 // Reset pool
 func (p *TransactionPool) Reset() *TransactionPool {
 	p.m.Lock()
-	p.transactions = make(map[TransactionId]*SqlTx)
+	p.transactions = make(map[TransactionId]*poolEntry)
+	p.m.Unlock()
+	return p
+}
+
+// SetLimit set maximum number of pooled transactions. 0 - unlimited
+func (p *TransactionPool) SetLimit(limit int) *TransactionPool {
+	p.m.Lock()
+	p.limit = limit
 	p.m.Unlock()
 	return p
 }
 
 // Count transaction count
 func (p *TransactionPool) Count() int {
+	p.m.RLock()
+	defer p.m.RUnlock()
 	return len(p.transactions)
 }
 
+// Range call fn for each pooled transaction until it returns false
+// Pool is not locked during call so fn can commit or rollback transactions
+func (p *TransactionPool) Range(fn func(info TransactionInfo) bool) {
+	for _, info := range p.List() {
+		if !fn(info) {
+			return
+		}
+	}
+}
+
+// List pooled transactions ordered by start time
+func (p *TransactionPool) List() []TransactionInfo {
+	p.m.RLock()
+	result := make([]TransactionInfo, 0, len(p.transactions))
+	for id, e := range p.transactions {
+		result = append(result, e.info(id))
+	}
+	p.m.RUnlock()
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].StartedAt.Before(result[j].StartedAt)
+	})
+	return result
+}
+
+// OldestAge age of the oldest transaction
+func (p *TransactionPool) OldestAge() time.Duration {
+	list := p.List()
+	if len(list) == 0 {
+		return 0
+	}
+	return list[0].Age
+}
+
+// RollbackAll rollback all pooled transactions. First rollback error is returned
+func (p *TransactionPool) RollbackAll() error {
+	_, err := p.RollbackOlderThan(0)
+	return err
+}
+
+// RollbackOlderThan rollback pooled transactions older than age. Count of rolled back transactions is returned
+func (p *TransactionPool) RollbackOlderThan(age time.Duration) (int, error) {
+	var count int
+	var err error
+	for _, info := range p.List() {
+		if info.Age < age || info.Tx == nil {
+			continue
+		}
+		e := info.Tx.Rollback()
+		switch e {
+		case nil:
+			count++
+		case ErrTxExpired, ErrTxRolledBack, ErrTxCommitted:
+		default:
+			if err == nil {
+				err = e
+			}
+		}
+		p.m.Lock()
+		if entry, ok := p.transactions[info.Id]; ok && entry.tx == info.Tx {
+			delete(p.transactions, info.Id)
+		}
+		p.m.Unlock()
+	}
+	return count, err
+}
+
 // NewTransactionPool Create transaction pool
 func NewTransactionPool() *TransactionPool {
 	return &TransactionPool{
-		transactions: make(map[TransactionId]*SqlTx),
+		transactions: make(map[TransactionId]*poolEntry),
 	}
 }
@@ -1,11 +1,13 @@
 package godb
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"github.com/dimonrus/gocli"
 	// _ "github.com/lib/pq"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -49,7 +51,7 @@ func TestAsyncTransaction(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	db.TransactionTTL = 4
+	db.TransactionTTL = time.Second * 4
 	tx, err := db.Begin()
 	if err != nil {
 		t.Fatal(err)
@@ -161,7 +163,7 @@ func BenchmarkTransaction(b *testing.B) {
 		b.Fatal(err)
 	}
 	db.Options.Debug = false
-	db.TransactionTTL = 4
+	db.TransactionTTL = time.Second * 4
 	for i := 0; i < b.N; i++ {
 		tx, err := db.Begin()
 		if err != nil {
@@ -189,7 +191,7 @@ func TestTransactionPool(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	db.TransactionTTL = 3
+	db.TransactionTTL = time.Second * 3
 	tx, err := db.Begin()
 	if err != nil {
 		t.Fatal(err)
@@ -209,18 +211,426 @@ func TestTransactionPool(t *testing.T) {
 
 	time.Sleep(time.Second * 4)
 
-	tx = pool.Get(tx2)
-	if tx != nil {
-		_, err = tx.Exec("select version();")
-		if err == sql.ErrTxDone {
-			pool.UnSet(tx2)
-		} else {
+	// expired transactions are removed from pool
+	if pool.Get(tx2) != nil || pool.Count() != 0 {
+		t.Fatal("pool have to be empty")
+	}
+}
+
+func TestTransactionPoolLifecycle(t *testing.T) {
+	db := initSqlite(t, Options{TransactionTTL: time.Second})
+	t.Run("unregister", func(t *testing.T) {
+		pool := NewTransactionPool()
+		ids := make([]TransactionId, 3)
+		for i := range ids {
+			tx, err := db.BeginContext(context.Background(), &TxOptions{TTL: time.Millisecond * 50 * time.Duration(i+1)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids[i] = GenTransactionId()
+			err = pool.Register(ids[i], tx, "user")
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		list := pool.List()
+		if len(list) != 3 || list[0].Id != ids[0] || list[0].Owner != "user" || list[2].TTL != time.Millisecond*150 {
+			t.Fatal("wrong list", list)
+		}
+		if err := pool.Get(ids[1]).Commit(); err != nil {
 			t.Fatal(err)
 		}
-	}
+		if err := pool.Get(ids[2]).Rollback(); err != nil {
+			t.Fatal(err)
+		}
+		if pool.Count() != 1 {
+			t.Fatal("committed and rolled back transactions must be removed")
+		}
+		time.Sleep(time.Millisecond * 80)
+		if pool.Count() != 0 {
+			t.Fatal("expired transaction must be removed")
+		}
+	})
+	t.Run("limit", func(t *testing.T) {
+		pool := NewTransactionPool().SetLimit(1)
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tx.Rollback()
+		if err = pool.Register(GenTransactionId(), tx, ""); err != nil {
+			t.Fatal(err)
+		}
+		if err = pool.Register(GenTransactionId(), tx, ""); err != ErrPoolFull {
+			t.Fatal("pool must be full", err)
+		}
+	})
+	t.Run("rollback", func(t *testing.T) {
+		pool := NewTransactionPool()
+		old, err := db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		pool.Set(GenTransactionId(), old)
+		time.Sleep(time.Millisecond * 50)
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		pool.Set(GenTransactionId(), tx)
+		count, err := pool.RollbackOlderThan(time.Millisecond * 50)
+		if err != nil || count != 1 {
+			t.Fatal("one transaction must be rolled back", count, err)
+		}
+		if old.Transaction().State() != TxRolledBack || pool.Count() != 1 {
+			t.Fatal("old transaction must be rolled back and removed")
+		}
+		var ages []time.Duration
+		pool.Range(func(info TransactionInfo) bool {
+			ages = append(ages, info.Age)
+			return true
+		})
+		if len(ages) != 1 {
+			t.Fatal("wrong range", ages)
+		}
+		if err = pool.RollbackAll(); err != nil || pool.Count() != 0 {
+			t.Fatal("all transactions must be rolled back", err)
+		}
+	})
+}
 
-	time.Sleep(time.Second * 3)
-	if pool.Count() != 1 {
-		t.Fatal("pool have to contain 1 transaction")
+func TestTransactionTTL(t *testing.T) {
+	db := initSqlite(t, Options{TransactionTTL: time.Millisecond * 100})
+	t.Run("expired", func(t *testing.T) {
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		deadline, ok := tx.Transaction().Deadline()
+		if !ok || deadline.Sub(tx.Transaction().StartedAt) != time.Millisecond*100 {
+			t.Fatal("wrong deadline", deadline)
+		}
+		var count int
+		// infinite query must be cancelled on expiry
+		err = tx.QueryRow("WITH RECURSIVE c(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM c) SELECT count(*) FROM c").Scan(&count)
+		if err == nil {
+			t.Fatal("query must be cancelled")
+		}
+		if !tx.Transaction().Expired() {
+			t.Fatal("transaction must be expired")
+		}
+		if err = tx.Commit(); err != ErrTxExpired {
+			t.Fatal("commit must return ErrTxExpired", err)
+		}
+	})
+	t.Run("extend", func(t *testing.T) {
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = tx.Transaction().Extend(time.Millisecond * 200)
+		if err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond * 150)
+		remaining, ok := tx.Transaction().Remaining()
+		if !ok || remaining <= 0 || remaining > time.Millisecond*150 {
+			t.Fatal("wrong remaining time", remaining)
+		}
+		_, err = tx.Exec("SELECT 1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = tx.Commit()
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("override", func(t *testing.T) {
+		tx, err := db.BeginContext(context.Background(), &TxOptions{TTL: -1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := tx.Transaction().Deadline(); ok {
+			t.Fatal("transaction must not have deadline")
+		}
+		time.Sleep(time.Millisecond * 150)
+		_, err = tx.Exec("SELECT 1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = tx.Rollback()
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestTransactionContext(t *testing.T) {
+	db := initSqlite(t, Options{})
+	db.TransactionPool = NewTransactionPool()
+	ctx, cancel := context.WithCancel(context.Background())
+	tx, err := db.BeginContext(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reason := make(chan error, 1)
+	tx.OnRollback(func(err error) {
+		reason <- err
+	})
+	id, err := db.TransactionPool.Add(tx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	select {
+	case err = <-reason:
+		if err != context.Canceled {
+			t.Fatal("rollback hook must receive context error", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("rollback hook must be called when context is cancelled")
+	}
+	if tx.Transaction().State() != TxRolledBack {
+		t.Fatal("transaction must be rolled back", tx.Transaction().State())
+	}
+	if db.TransactionPool.Get(id) != nil {
+		t.Fatal("transaction must be removed from pool")
 	}
+	if len(db.state.activeTransactions()) != 0 {
+		t.Fatal("transaction must not be active")
+	}
+	if err = tx.Commit(); err != ErrTxRolledBack {
+		t.Fatal("commit must return ErrTxRolledBack", err)
+	}
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), time.Second)
+	defer shutdownCancel()
+	if err = db.Shutdown(shutdownCtx); err != nil {
+		t.Fatal("shutdown must not wait for cancelled transaction", err)
+	}
+}
+
+func TestTransactionLifecycle(t *testing.T) {
+	db := initSqlite(t, Options{})
+	t.Run("idempotent", func(t *testing.T) {
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		if err = tx.Commit(); err != nil {
+			t.Fatal("repeated commit must be no-op", err)
+		}
+		if err = tx.Rollback(); err != ErrTxCommitted {
+			t.Fatal("rollback must return ErrTxCommitted", err)
+		}
+		tx, err = db.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = tx.Rollback(); err != nil {
+			t.Fatal(err)
+		}
+		if err = tx.Rollback(); err != nil {
+			t.Fatal("repeated rollback must be no-op", err)
+		}
+		if err = tx.Commit(); err != ErrTxRolledBack {
+			t.Fatal("commit must return ErrTxRolledBack", err)
+		}
+		if tx.Transaction().State() != TxRolledBack {
+			t.Fatal("wrong state", tx.Transaction().State())
+		}
+	})
+	t.Run("expired", func(t *testing.T) {
+		tx, err := db.BeginContext(context.Background(), &TxOptions{TTL: time.Millisecond})
+		if err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond * 20)
+		done := make(chan error)
+		go func() {
+			done <- tx.Commit()
+		}()
+		select {
+		case err = <-done:
+			if err != ErrTxExpired {
+				t.Fatal("commit must return ErrTxExpired", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("commit after expiry must not block")
+		}
+		if err = tx.Rollback(); err != ErrTxExpired {
+			t.Fatal("rollback must return ErrTxExpired", err)
+		}
+		if err = tx.Transaction().Extend(time.Second); err != ErrTxExpired {
+			t.Fatal("extend must return ErrTxExpired", err)
+		}
+	})
+	t.Run("race", func(t *testing.T) {
+		for i := 0; i < 50; i++ {
+			tx, err := db.BeginContext(context.Background(), &TxOptions{TTL: time.Duration(i%5) * time.Millisecond})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var wg sync.WaitGroup
+			var m sync.Mutex
+			results := make(map[error]int)
+			for j := 0; j < 4; j++ {
+				wg.Add(1)
+				go func(j int) {
+					defer wg.Done()
+					var err error
+					if j%2 == 0 {
+						err = tx.Commit()
+					} else {
+						err = tx.Rollback()
+					}
+					m.Lock()
+					results[err]++
+					m.Unlock()
+				}(j)
+			}
+			wg.Wait()
+			switch tx.Transaction().State() {
+			case TxCommitted:
+				if results[nil] != 2 || results[ErrTxCommitted] != 2 {
+					t.Fatal("wrong results for committed", results)
+				}
+			case TxRolledBack:
+				if results[nil] != 2 || results[ErrTxRolledBack] != 2 {
+					t.Fatal("wrong results for rolled back", results)
+				}
+			case TxExpired:
+				if results[ErrTxExpired] != 4 {
+					t.Fatal("wrong results for expired", results)
+				}
+			default:
+				t.Fatal("transaction must be finished")
+			}
+		}
+	})
+	t.Run("cancel_race", func(t *testing.T) {
+		for i := 0; i < 50; i++ {
+			ctx, cancel := context.WithCancel(context.Background())
+			tx, err := db.BeginContext(ctx, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var hooks int32
+			tx.OnCommit(func() {
+				atomic.AddInt32(&hooks, 1)
+			})
+			tx.OnRollback(func(err error) {
+				atomic.AddInt32(&hooks, 1)
+			})
+			var wg sync.WaitGroup
+			var commitErr, rollbackErr error
+			wg.Add(3)
+			go func() {
+				defer wg.Done()
+				commitErr = tx.Commit()
+			}()
+			go func() {
+				defer wg.Done()
+				rollbackErr = tx.Rollback()
+			}()
+			go func() {
+				defer wg.Done()
+				cancel()
+			}()
+			wg.Wait()
+			switch tx.Transaction().State() {
+			case TxCommitted:
+				if commitErr != nil || rollbackErr != ErrTxCommitted {
+					t.Fatal("wrong results for committed", commitErr, rollbackErr)
+				}
+			case TxRolledBack:
+				if commitErr == nil || rollbackErr != nil {
+					t.Fatal("wrong results for rolled back", commitErr, rollbackErr)
+				}
+			default:
+				t.Fatal("transaction must be finished", tx.Transaction().State())
+			}
+			if atomic.LoadInt32(&hooks) != 1 {
+				t.Fatal("hooks must be called once", hooks)
+			}
+			if len(db.state.activeTransactions()) != 0 {
+				t.Fatal("transaction must not be active")
+			}
+		}
+	})
+}
+
+func TestGenTransactionId(t *testing.T) {
+	t.Run("sortable", func(t *testing.T) {
+		ids := make(map[TransactionId]struct{})
+		prev := GenTransactionId()
+		for i := 0; i < 1000; i++ {
+			if i%100 == 0 {
+				time.Sleep(time.Millisecond * 2)
+			}
+			id := GenTransactionId()
+			if err := ValidateTransactionId(id); err != nil {
+				t.Fatal(id, err)
+			}
+			if id[:10] < prev[:10] {
+				t.Fatal("identifiers must be sorted by time", prev, id)
+			}
+			if _, ok := ids[id]; ok {
+				t.Fatal("duplicate identifier", id)
+			}
+			ids[id] = struct{}{}
+			prev = id
+		}
+	})
+	t.Run("validate", func(t *testing.T) {
+		for _, id := range []TransactionId{"", "01ARZ3NDEKTSV4RRFFQ69G5FA", "81ARZ3NDEKTSV4RRFFQ69G5FAV", "01ARZ3NDEKTSV4RRFFQ69G5FAU", "01arz3ndektsv4rrffq69g5fav"} {
+			if ValidateTransactionId(id) != ErrInvalidTransactionId {
+				t.Fatal("identifier must be invalid", id)
+			}
+		}
+		if ValidateTransactionId("01ARZ3NDEKTSV4RRFFQ69G5FAV") != nil {
+			t.Fatal("identifier must be valid")
+		}
+	})
+	t.Run("collision", func(t *testing.T) {
+		db := initSqlite(t, Options{})
+		var n int
+		pool := NewTransactionPool().SetIdGenerator(func() TransactionId {
+			n++
+			return TransactionId("id" + string(rune('0'+n/2)))
+		}, nil)
+		tx1, _ := db.Begin()
+		defer tx1.Rollback()
+		tx2, _ := db.Begin()
+		defer tx2.Rollback()
+		id1, err := pool.Add(tx1, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = pool.Register(id1, tx2, ""); err != ErrTransactionIdExists {
+			t.Fatal("collision must be detected", err)
+		}
+		id2, err := pool.Add(tx2, "")
+		if err != nil || id2 == id1 {
+			t.Fatal("new identifier must be generated on collision", id1, id2, err)
+		}
+		if pool.Validate("anything") != nil {
+			t.Fatal("nil validator must accept any identifier")
+		}
+		if err = pool.Set(id1, tx2); err != ErrTransactionIdExists {
+			t.Fatal("set must return collision", err)
+		}
+		if pool.Get(id1) != tx1 {
+			t.Fatal("set must not replace other transaction")
+		}
+		pool.SetIdGenerator(func() TransactionId {
+			return id1
+		}, nil)
+		if _, err = pool.Add(tx2, ""); err != ErrTransactionIdExists {
+			t.Fatal("generator repeating used identifier must fail", err)
+		}
+	})
 }
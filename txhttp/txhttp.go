@@ -0,0 +1,162 @@
+package txhttp
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/dimonrus/godb/v2"
+	"net/http"
+	"time"
+)
+
+// HeaderTransactionId default header with transaction identifier
+const HeaderTransactionId = "X-Transaction-Id"
+
+// context key of transaction
+type contextKey struct{}
+
+// Response of begin handler
+type Response struct {
+	// Transaction identifier
+	TransactionId godb.TransactionId `json:"transactionId"`
+	// Time of TTL expiry
+	Deadline *time.Time `json:"deadline,omitempty"`
+}
+
+// Manager multi-request transactions over http
+type Manager struct {
+	// Database object
+	DBO *godb.DBO
+	// Pool of shared transactions
+	Pool *godb.TransactionPool
+	// Options of new transactions
+	TxOptions *godb.TxOptions
+	// Header with transaction identifier. Default HeaderTransactionId
+	Header string
+	// Owner label of request. Transaction is available only for requests with the same owner
+	Owner func(r *http.Request) string
+}
+
+// FromContext get transaction attached by middleware
+func FromContext(ctx context.Context) (*godb.SqlTx, bool) {
+	tx, ok := ctx.Value(contextKey{}).(*godb.SqlTx)
+	return tx, ok
+}
+
+// NewContext attach transaction to context
+func NewContext(ctx context.Context, tx *godb.SqlTx) context.Context {
+	return context.WithValue(ctx, contextKey{}, tx)
+}
+
+// Queryer get transaction from context or database object if there is no transaction
+func (m *Manager) Queryer(ctx context.Context) godb.Queryer {
+	if tx, ok := FromContext(ctx); ok {
+		return tx
+	}
+	return m.DBO
+}
+
+// header name
+func (m *Manager) header() string {
+	if m.Header == "" {
+		return HeaderTransactionId
+	}
+	return m.Header
+}
+
+// owner of request
+func (m *Manager) owner(r *http.Request) string {
+	if m.Owner == nil {
+		return ""
+	}
+	return m.Owner(r)
+}
+
+// lookup transaction of request. Http status is returned if transaction is not available
+func (m *Manager) lookup(r *http.Request) (godb.TransactionId, *godb.SqlTx, int) {
+	id := godb.TransactionId(r.Header.Get(m.header()))
+	if m.Pool.Validate(id) != nil {
+		return id, nil, http.StatusBadRequest
+	}
+	info, ok := m.Pool.Info(id)
+	if !ok || info.Tx == nil {
+		return id, nil, http.StatusNotFound
+	}
+	if info.Owner != m.owner(r) {
+		return id, nil, http.StatusForbidden
+	}
+	return id, info.Tx, 0
+}
+
+// Middleware attach transaction from header to request context
+// Requests without header are passed as is
+func (m *Manager) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(m.header()) == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		id, tx, status := m.lookup(r)
+		if status != 0 {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		w.Header().Set(m.header(), string(id))
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), tx)))
+	})
+}
+
+// BeginHandler begin transaction, store it in pool and respond with transaction identifier
+func (m *Manager) BeginHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// transaction outlives request so request context is not used
+		tx, err := m.DBO.BeginContext(context.Background(), m.TxOptions)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		id, err := m.Pool.Add(tx, m.owner(r))
+		if err != nil {
+			_ = tx.Rollback()
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		}
+		response := Response{TransactionId: id}
+		if deadline, ok := tx.Transaction().Deadline(); ok {
+			response.Deadline = &deadline
+		}
+		w.Header().Set(m.header(), string(id))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(response)
+	})
+}
+
+// CommitHandler commit transaction from header
+func (m *Manager) CommitHandler() http.Handler {
+	return m.finishHandler((*godb.SqlTx).Commit)
+}
+
+// RollbackHandler rollback transaction from header
+func (m *Manager) RollbackHandler() http.Handler {
+	return m.finishHandler((*godb.SqlTx).Rollback)
+}
+
+// finish transaction from header
+func (m *Manager) finishHandler(finish func(tx *godb.SqlTx) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, tx, status := m.lookup(r)
+		if status != 0 {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		err := finish(tx)
+		switch err {
+		case nil:
+			w.WriteHeader(http.StatusNoContent)
+		case godb.ErrTxExpired, godb.ErrTxCommitted, godb.ErrTxRolledBack:
+			http.Error(w, err.Error(), http.StatusGone)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
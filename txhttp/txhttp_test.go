@@ -0,0 +1,164 @@
+package txhttp
+
+import (
+	"encoding/json"
+	"github.com/dimonrus/godb/v2"
+	"github.com/dimonrus/godb/v2/internal/sqlitetest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func initManager(t *testing.T) (*Manager, *httptest.Server) {
+	db := sqlitetest.Init(t, godb.Options{})
+	_, err := db.Exec("CREATE TABLE users (id INTEGER NOT NULL PRIMARY KEY, name TEXT)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &Manager{
+		DBO:       db,
+		Pool:      godb.NewTransactionPool(),
+		TxOptions: &godb.TxOptions{TTL: time.Second},
+		Owner: func(r *http.Request) string {
+			return r.Header.Get("X-User")
+		},
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/begin", m.BeginHandler())
+	mux.Handle("/commit", m.CommitHandler())
+	mux.Handle("/rollback", m.RollbackHandler())
+	mux.Handle("/users", m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := m.Queryer(r.Context())
+		if r.Method == http.MethodPost {
+			_, err := q.Exec("INSERT INTO users (name) VALUES (?)", r.URL.Query().Get("name"))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		var count int
+		err := q.QueryRow("SELECT count(*) FROM users").Scan(&count)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(count)
+	})))
+	server := httptest.NewServer(mux)
+	t.Cleanup(func() {
+		server.Close()
+	})
+	return m, server
+}
+
+func request(t *testing.T, method, url string, id godb.TransactionId) *http.Response {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-User", "john")
+	if id != "" {
+		req.Header.Set(HeaderTransactionId, string(id))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		resp.Body.Close()
+	})
+	return resp
+}
+
+func begin(t *testing.T, url string) godb.TransactionId {
+	resp := request(t, http.MethodPost, url+"/begin", "")
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatal("wrong begin status", resp.StatusCode)
+	}
+	response := Response{}
+	err := json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.TransactionId == "" || string(response.TransactionId) != resp.Header.Get(HeaderTransactionId) || response.Deadline == nil {
+		t.Fatal("wrong begin response", response)
+	}
+	return response.TransactionId
+}
+
+func count(t *testing.T, url string, id godb.TransactionId) int {
+	resp := request(t, http.MethodGet, url+"/users", id)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatal("wrong count status", resp.StatusCode)
+	}
+	var c int
+	err := json.NewDecoder(resp.Body).Decode(&c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestManager(t *testing.T) {
+	m, server := initManager(t)
+	t.Run("commit", func(t *testing.T) {
+		id := begin(t, server.URL)
+		if request(t, http.MethodPost, server.URL+"/users?name=John", id).StatusCode != http.StatusOK {
+			t.Fatal("insert failed")
+		}
+		if count(t, server.URL, id) != 1 {
+			t.Fatal("row must be visible in transaction")
+		}
+		if request(t, http.MethodPost, server.URL+"/commit", id).StatusCode != http.StatusNoContent {
+			t.Fatal("commit failed")
+		}
+		if count(t, server.URL, "") != 1 {
+			t.Fatal("row must be committed")
+		}
+		if request(t, http.MethodPost, server.URL+"/commit", id).StatusCode != http.StatusNotFound {
+			t.Fatal("committed transaction must be removed from pool")
+		}
+	})
+	t.Run("rollback", func(t *testing.T) {
+		id := begin(t, server.URL)
+		request(t, http.MethodPost, server.URL+"/users?name=Ksenia", id)
+		if request(t, http.MethodPost, server.URL+"/rollback", id).StatusCode != http.StatusNoContent {
+			t.Fatal("rollback failed")
+		}
+		if count(t, server.URL, "") != 1 {
+			t.Fatal("row must be rolled back")
+		}
+	})
+	t.Run("owner", func(t *testing.T) {
+		id := begin(t, server.URL)
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/users", nil)
+		req.Header.Set(HeaderTransactionId, string(id))
+		req.Header.Set("X-User", "mallory")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Fatal("transaction must not be available for other owner", resp.StatusCode)
+		}
+		request(t, http.MethodPost, server.URL+"/rollback", id)
+	})
+	t.Run("invalid", func(t *testing.T) {
+		if request(t, http.MethodGet, server.URL+"/users", "../../etc/passwd").StatusCode != http.StatusBadRequest {
+			t.Fatal("invalid transaction id must be rejected")
+		}
+	})
+	t.Run("expired", func(t *testing.T) {
+		m.TxOptions = &godb.TxOptions{TTL: time.Millisecond * 20}
+		id := begin(t, server.URL)
+		time.Sleep(time.Millisecond * 50)
+		if request(t, http.MethodGet, server.URL+"/users", id).StatusCode != http.StatusNotFound {
+			t.Fatal("expired transaction must not be found")
+		}
+		if m.Pool.Count() != 0 {
+			t.Fatal("pool must be empty")
+		}
+	})
+}
@@ -1,12 +1,20 @@
 package godb
 
 import (
+	"context"
 	"database/sql"
 	"github.com/dimonrus/gocli"
 	"sync"
 	"time"
 )
 
+const (
+	// DefaultLogBufferSize log messages buffer size if connection has no max connections limit
+	DefaultLogBufferSize = 100
+	// DefaultConnectBackoff delay between connect attempts if backoff is not set
+	DefaultConnectBackoff = time.Second
+)
+
 // Connection Database Object Connection Interface
 type Connection interface {
 	// String Get prepared URI
@@ -21,6 +29,14 @@ type Connection interface {
 	GetMaxIdleConns() int
 }
 
+// ConnectionTimeouts connection with pool lifetime durations
+type ConnectionTimeouts interface {
+	// GetConnectionMaxLifetime return maximum amount of time a connection may be reused
+	GetConnectionMaxLifetime() time.Duration
+	// GetConnectionMaxIdleTime return maximum amount of time a connection may be idle
+	GetConnectionMaxIdleTime() time.Duration
+}
+
 // Queryer interface
 type Queryer interface {
 	// Exec query
@@ -39,12 +55,43 @@ type Options struct {
 	Debug bool
 	// Logger
 	Logger gocli.Logger
-	// log message data
-	logMessage chan string
+	// query log writer
+	queryLog *queryLogger
 	// Query preprocessing
 	QueryProcessor func(query string) string
 	// TTL for transaction
 	TransactionTTL time.Duration `yaml:"transactionTTL"`
+	// Query log buffer size. Default is maximum connections or DefaultLogBufferSize
+	LogBufferSize int `yaml:"logBufferSize"`
+	// Behaviour when query log buffer is full. Default is LogDropNewest
+	LogPolicy LogPolicy `yaml:"logPolicy"`
+	// Maximum messages written to logger at once. Default is DefaultLogBatchSize
+	LogBatchSize int `yaml:"logBatchSize"`
+	// Write query log synchronously. Useful for tests
+	LogSync bool `yaml:"logSync"`
+	// Initial connect retry policy
+	ConnectRetry ConnectRetry `yaml:"connectRetry"`
+	// Skip ping on init. Connections are opened on first query
+	LazyConnect bool `yaml:"lazyConnect"`
+	// Count of prepared statements cached by processed query. 0 disables cache
+	// Only single statement queries with arguments are cached
+	StatementCacheSize int `yaml:"statementCacheSize"`
+	// Statements executed on every new physical connection, e.g. SET statement_timeout = '5s'
+	SessionInit []string `yaml:"sessionInit"`
+	// Called on every new physical connection after SessionInit. Connection is closed if error is returned
+	OnConnect func(ctx context.Context, conn *SessionConn) error
+}
+
+// ConnectRetry initial connect retry policy
+type ConnectRetry struct {
+	// Maximum connect attempts. 0 means single attempt, negative means retry until timeout
+	Attempts int `yaml:"attempts"`
+	// Delay after first failed attempt. Doubles after each next attempt
+	Backoff time.Duration `yaml:"backoff"`
+	// Maximum delay between attempts
+	MaxBackoff time.Duration `yaml:"maxBackoff"`
+	// Overall connect timeout
+	Timeout time.Duration `yaml:"timeout"`
 }
 
 // IOptions interface helps to get logger
@@ -58,17 +105,38 @@ func (o *Options) GetLogger() gocli.Logger {
 	return o.Logger
 }
 
+// Log query in debug mode
+func (o *Options) logQuery(query string) {
+	if o.Debug && o.queryLog != nil {
+		o.queryLog.write(query)
+	}
+}
+
 // IConnType interface helps to get connection type
 type IConnType interface {
 	// ConnType return connection type
 	ConnType() string
 }
 
+// Dialect connection type of queryer. Empty if queryer does not implement IConnType
+func Dialect(q Queryer) string {
+	if c, ok := q.(IConnType); ok {
+		return c.ConnType()
+	}
+	return ""
+}
+
 // DBO Main Database Object
 type DBO struct {
 	*sql.DB
 	Options
 	Connection Connection
+	// Pool of transactions shared between requests. Used for health report
+	TransactionPool *TransactionPool
+	// runtime state
+	state *dboState
+	// prepared statements cache
+	stmtCache *stmtCache
 }
 
 // SqlTx Transaction object
@@ -78,12 +146,39 @@ type SqlTx struct {
 	Options
 	transaction *Transaction
 	Connection  Connection
+	// database object state tracks active transactions
+	state *dboState
+	// prepared statements cache of database object
+	stmtCache *stmtCache
+	// mysql execution time limit of SELECT queries
+	statementTimeout time.Duration
+}
+
+// TxOptions transaction options
+type TxOptions struct {
+	// Isolation level
+	Isolation sql.IsolationLevel
+	// Read only transaction
+	ReadOnly bool
+	// Time to live. Overrides Options.TransactionTTL, negative disables TTL
+	TTL time.Duration
+	// Server side timeout of each statement limited by context deadline. See SqlTx.SetStatementTimeout
+	StatementTimeout time.Duration
 }
 
 // SqlStmt Statement object
 type SqlStmt struct {
-	m sync.Mutex
 	*sql.Stmt
 	Options
+	// processed query
 	query string
+	// count of arguments
+	args int
+	// context of statement execution
+	ctx context.Context
+}
+
+// Create statement for query processed at prepare time. Arguments are counted in query before processing
+func newStmt(stmt *sql.Stmt, options Options, query string, args int, ctx context.Context) *SqlStmt {
+	return &SqlStmt{Stmt: stmt, Options: options, query: query, args: args, ctx: ctx}
 }
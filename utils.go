@@ -1,22 +1,15 @@
 package godb
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
-	"github.com/dimonrus/gocli"
+	"database/sql/driver"
 	"strconv"
 	"strings"
 	"time"
 	"unsafe"
 )
 
-// init logger callback
-var logger = func(lg gocli.Logger, message chan string) {
-	for s := range message {
-		lg.Println(s)
-	}
-}
-
 // cache for positional args
 var positionalArgs = prepareAllPositions()
 
@@ -29,25 +22,149 @@ func prepareAllPositions() []string {
 	return result
 }
 
-// IsTableExists check if table exists
-func IsTableExists(q Queryer, table, schema string) bool {
-	query := fmt.Sprintf(`SELECT table_name FROM information_schema.tables WHERE table_name = '%s'`, table)
-	if schema != "" {
-		query += fmt.Sprintf(" AND table_schema = '%s'", schema)
+// Placeholder of n-th query argument for dialect: $n for postgres, ? for others
+func Placeholder(dialect string, n int) string {
+	if dialect != "postgres" {
+		return "?"
 	}
-	if c, ok := q.(IConnType); ok {
-		if c.ConnType() == "sqlite3" {
-			query = fmt.Sprintf("SELECT name FROM sqlite_master WHERE type='table' AND name='%s'", table)
-		}
+	if n > 0 && n < len(positionalArgs) {
+		return positionalArgs[n]
 	}
-	var tableName *string
-	err := q.QueryRow(query).Scan(&tableName)
+	return "$" + strconv.Itoa(n)
+}
+
+// IsTableExists check if table exists. Errors are logged
+// Deprecated: use TableExists
+func IsTableExists(q Queryer, table, schema string) bool {
+	ok, err := TableExists(q, table, schema)
 	if err != nil {
-		if o, ok := q.(IOptions); ok {
+		if o, isOptions := q.(IOptions); isOptions && o.GetLogger() != nil {
 			o.GetLogger().Errorln(err.Error())
 		}
 	}
-	return err == nil && tableName != nil && *tableName == table
+	return ok
+}
+
+// Count query arguments. Maximum $n position is used if query contains positional args, otherwise count of ?
+// Escaped ?? is not an argument as in PreparePositionalArgsQuery
+// Quoted strings and identifiers, comments and dollar quoted bodies are skipped
+func countArgs(query string) int {
+	var count, max int
+	for i := 0; i < len(query); i++ {
+		switch c := query[i]; c {
+		case '?':
+			if i+1 < len(query) && query[i+1] == '?' {
+				i++
+			} else {
+				count++
+			}
+		case '\'', '"', '`':
+			i = skipQuoted(query, i, c)
+		case '-', '/':
+			i = skipComment(query, i)
+		case '$':
+			var n, j int
+			for j = i + 1; j < len(query) && query[j] >= '0' && query[j] <= '9'; j++ {
+				n = n*10 + int(query[j]-'0')
+			}
+			if j > i+1 {
+				if n > max {
+					max = n
+				}
+				i = j - 1
+				continue
+			}
+			i = skipDollarQuoted(query, i)
+		}
+	}
+	if max > 0 {
+		return max
+	}
+	return count
+}
+
+// Check if query contains several statements separated by semicolon
+// Trailing semicolon, quoted strings and identifiers, comments and dollar quoted bodies are skipped
+func multiStatement(query string) bool {
+	var end bool
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+			continue
+		}
+		if j := skipComment(query, i); j != i {
+			i = j
+			continue
+		}
+		if c == ';' {
+			end = true
+			continue
+		}
+		if end {
+			return true
+		}
+		switch c {
+		case '\'', '"', '`':
+			i = skipQuoted(query, i, c)
+		case '$':
+			i = skipDollarQuoted(query, i)
+		}
+	}
+	return false
+}
+
+// skip line or block comment started at i. Index of last char of comment is returned
+// i is returned if there is no comment at i
+func skipComment(query string, i int) int {
+	if i+1 >= len(query) {
+		return i
+	}
+	if query[i] == '-' && query[i+1] == '-' {
+		for i < len(query) && query[i] != '\n' {
+			i++
+		}
+		return i
+	}
+	if query[i] == '/' && query[i+1] == '*' {
+		if end := strings.Index(query[i+2:], "*/"); end >= 0 {
+			return i + end + 3
+		}
+		return len(query)
+	}
+	return i
+}
+
+// skip quoted string or identifier started at i. Doubled quote is escaped quote. Index of closing quote is returned
+func skipQuoted(query string, i int, quote byte) int {
+	for i++; i < len(query); i++ {
+		if query[i] == quote {
+			if i+1 < len(query) && query[i+1] == quote {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return i
+}
+
+// skip dollar quoted body $tag$...$tag$ started at i. Index of last char of closing tag is returned
+// i is returned if there is no dollar quote at i
+func skipDollarQuoted(query string, i int) int {
+	j := i + 1
+	for j < len(query) && (query[j] == '_' || query[j] >= 'a' && query[j] <= 'z' || query[j] >= 'A' && query[j] <= 'Z' ||
+		j > i+1 && query[j] >= '0' && query[j] <= '9') {
+		j++
+	}
+	if j >= len(query) || query[j] != '$' {
+		return i
+	}
+	tag := query[i : j+1]
+	end := strings.Index(query[j+1:], tag)
+	if end < 0 {
+		return len(query)
+	}
+	return j + end + len(tag)
 }
 
 // PreparePositionalArgsQuery Position argument
@@ -100,20 +217,92 @@ func PreparePositionalArgsQuery(query string) string {
 }
 
 // Get Db Instance
-func getDb(connection Connection) (*sql.DB, error) {
+func getDb(ctx context.Context, connection Connection, options Options) (*sql.DB, error) {
 	//Open connection
-	dbo, err := sql.Open(connection.GetDbType(), connection.String())
+	dbo, err := openDb(connection, options)
 	if err != nil {
 		return nil, err
 	}
+	// Set connection options
+	dbo.SetMaxIdleConns(connection.GetMaxIdleConns())
+	if t, ok := connection.(ConnectionTimeouts); ok {
+		dbo.SetConnMaxLifetime(t.GetConnectionMaxLifetime())
+		dbo.SetConnMaxIdleTime(t.GetConnectionMaxIdleTime())
+	} else {
+		dbo.SetConnMaxLifetime(time.Second * time.Duration(connection.GetConnMaxLifetime()))
+	}
+	dbo.SetMaxOpenConns(connection.GetMaxConnection())
+	if options.LazyConnect {
+		return dbo, nil
+	}
 	// Ping db
-	err = dbo.Ping()
+	err = pingDb(ctx, dbo, options)
 	if err != nil {
+		dbo.Close()
 		return nil, err
 	}
-	// Set connection options
-	dbo.SetMaxIdleConns(connection.GetMaxIdleConns())
-	dbo.SetConnMaxLifetime(time.Second * time.Duration(connection.GetConnMaxLifetime()))
-	dbo.SetMaxOpenConns(connection.GetMaxConnection())
 	return dbo, nil
 }
+
+// Ping db until success according to retry policy
+func pingDb(ctx context.Context, db *sql.DB, options Options) error {
+	retry := options.ConnectRetry
+	if retry.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, retry.Timeout)
+		defer cancel()
+	}
+	backoff := retry.Backoff
+	if backoff <= 0 {
+		backoff = DefaultConnectBackoff
+	}
+	for attempt := 1; ; attempt++ {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+		if options.Logger != nil {
+			options.Logger.Warnf("database connect attempt %d failed: %s", attempt, err)
+		}
+		if retry.Attempts >= 0 && attempt >= retry.Attempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if retry.MaxBackoff > 0 && backoff > retry.MaxBackoff {
+			backoff = retry.MaxBackoff
+		}
+	}
+}
+
+// Open db with driver connector if connection supports it or session init is set
+func openDb(connection Connection, options Options) (*sql.DB, error) {
+	c, ok := connection.(ConnectorConnection)
+	if !ok && len(options.SessionInit) == 0 && options.OnConnect == nil {
+		return sql.Open(connection.GetDbType(), connection.String())
+	}
+	d, err := lookupDriver(connection.GetDbType())
+	if err != nil {
+		return nil, err
+	}
+	var cn driver.Connector
+	if ok {
+		cn, err = c.Connector(d)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		dsn := connection.String()
+		cn = &connector{driver: d, dsn: func(ctx context.Context) (string, error) {
+			return dsn, nil
+		}}
+	}
+	if len(options.SessionInit) > 0 || options.OnConnect != nil {
+		cn = &sessionConnector{Connector: cn, dialect: connection.GetDbType(), options: options}
+	}
+	return sql.OpenDB(cn), nil
+}
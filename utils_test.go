@@ -85,3 +85,52 @@ func TestPreparePositionalArgsQuery(t *testing.T) {
 		}
 	})
 }
+
+func TestCountArgs(t *testing.T) {
+	cases := map[string]int{
+		"SELECT 1":                                  0,
+		"SELECT * FROM t WHERE a = ? AND b = ?":     2,
+		"SELECT * FROM t WHERE a = $1 OR b = $1":    1,
+		"SELECT * FROM t WHERE a = $2 AND b = $10":  10,
+		"SELECT data ?? 'k' FROM t WHERE id = ?":    1,
+		"SELECT '?', 'it''s ?' FROM t WHERE a = ?":  1,
+		`SELECT "a?" FROM t WHERE a = ?`:            1,
+		"SELECT `a?` FROM t WHERE a = ?":            1,
+		"SELECT 1 -- ?\nFROM t WHERE a = ?":         1,
+		"SELECT /* ? $3 */ a FROM t WHERE a = ?":    1,
+		"SELECT $$ $5 $$, $body$ $4 $body$, $1":     1,
+		"SELECT a FROM t WHERE b = $2 AND c = '$9'": 2,
+		"SELECT 'unterminated ?":                    0,
+	}
+	for query, count := range cases {
+		if n := countArgs(query); n != count {
+			t.Fatal("wrong args count", query, n)
+		}
+	}
+}
+
+func TestMultiStatement(t *testing.T) {
+	cases := map[string]bool{
+		"SELECT 1":                                 false,
+		"SELECT 1;":                                false,
+		"SELECT 1; -- done\n":                      false,
+		"SELECT ';' FROM t; /* ; */":               false,
+		"SELECT $$ ; $$, `;`, \";\"":               false,
+		"SELECT 1; SELECT 2":                       true,
+		"CREATE TABLE a (id INT);\nCREATE INDEX b": true,
+	}
+	for query, multi := range cases {
+		if multiStatement(query) != multi {
+			t.Fatal("wrong multi statement check", query)
+		}
+	}
+}
+
+func TestPlaceholder(t *testing.T) {
+	if Placeholder("postgres", 2) != "$2" || Placeholder("postgres", 70000) != "$70000" {
+		t.Fatal("wrong postgres placeholder")
+	}
+	if Placeholder("mysql", 2) != "?" || Placeholder("", 1) != "?" {
+		t.Fatal("wrong placeholder")
+	}
+}